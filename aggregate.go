@@ -0,0 +1,152 @@
+package journal
+
+import (
+	"io"
+	"slices"
+	"time"
+
+	"github.com/andreyvit/journal/segfile"
+)
+
+// Bucket is a histogram bucket, see Histogram.
+type Bucket struct {
+	Start time.Time
+	Count int
+}
+
+// Count returns the number of committed records matching the filter.
+// Finalized and sealed segments that lie entirely within the ID and time
+// ranges of the filter are counted from their headers without decoding;
+// only the boundary segments (and drafts) are read. Content filters
+// (DataPrefix and Match) require reading every segment in range. Limit,
+// Offset and Latest are ignored.
+func (j *Journal) Count(filter Filter) (int, error) {
+	var n int
+	err := j.aggregate(filter, func(h *segfile.Header) bool {
+		n += int(h.LastRecordNumber - h.FirstRecordNumber + 1)
+		return true
+	}, func(id, ts uint64) {
+		n++
+	})
+	return n, err
+}
+
+// Histogram returns the number of committed records matching the filter per
+// time bucket of the given duration, aligned to the Unix epoch. Only
+// non-empty buckets are returned, oldest first. Like Count, it avoids
+// decoding segments covered by the filter whose records all fall into
+// a single bucket. Limit, Offset and Latest are ignored.
+func (j *Journal) Histogram(filter Filter, bucket time.Duration) ([]Bucket, error) {
+	size := uint64(bucket.Milliseconds())
+	if size == 0 {
+		size = 1
+	}
+	counts := make(map[uint64]int)
+	err := j.aggregate(filter, func(h *segfile.Header) bool {
+		b := h.FirstTimestamp / size
+		if h.LastTimestamp/size != b {
+			return false
+		}
+		counts[b] += int(h.LastRecordNumber - h.FirstRecordNumber + 1)
+		return true
+	}, func(id, ts uint64) {
+		counts[ts/size]++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]uint64, 0, len(counts))
+	for b := range counts {
+		keys = append(keys, b)
+	}
+	slices.Sort(keys)
+	result := make([]Bucket, len(keys))
+	for i, b := range keys {
+		result[i] = Bucket{Start: ToTime(b * size), Count: counts[b]}
+	}
+	return result, nil
+}
+
+// aggregate visits the committed records matching the filter. For each
+// finalized or sealed segment that lies entirely within the ranges of the
+// filter, whole is offered the segment header first; if it returns false,
+// the segment is decoded and rec is called for each matching record.
+func (j *Journal) aggregate(filter Filter, whole func(h *segfile.Header) bool, rec func(id, ts uint64)) error {
+	segs, err := j.FindSegments(filter)
+	if err != nil {
+		return err
+	}
+	var done uint64 // last record ID accounted for
+	for len(segs) > 0 {
+		seg := segs[0]
+		segs = segs[1:]
+
+		last, err := j.aggregateSegment(seg, &filter, whole, rec)
+		if err == errFileGone {
+			// sealed or trimmed in the meantime
+			j.resetState()
+			filter.MinRecordID = max(filter.MinRecordID, done+1)
+			segs, err = j.FindSegments(filter)
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		done = max(done, last)
+	}
+	return nil
+}
+
+func (j *Journal) aggregateSegment(seg Segment, filter *Filter, whole func(h *segfile.Header) bool, rec func(id, ts uint64)) (uint64, error) {
+	var sr *segmentReader
+	if !seg.status.IsDraft() {
+		var h segfile.Header
+		if len(j.verifyKeys) > 0 {
+			// the header can only be trusted once its signature is verified
+			f, r, err := openSegment(j, seg)
+			if err != nil {
+				return 0, err
+			}
+			defer f.Close()
+			sr, h = r, r.h
+		} else {
+			var ext segfile.Extension
+			if err := loadSegmentHeader(j, &h, &ext, seg); err != nil {
+				return 0, err
+			}
+		}
+		if h.LastRecordNumber >= h.FirstRecordNumber {
+			if h.LastRecordNumber < filter.MinRecordID || h.LastTimestamp < filter.MinTimestamp {
+				return h.LastRecordNumber, nil // precedes the filter
+			}
+			// records are ordered, so matching both ends means matching all
+			covered := filter.matches(h.FirstRecordNumber, h.FirstTimestamp) && filter.matches(h.LastRecordNumber, h.LastTimestamp)
+			if covered && !filter.hasContentFilter() && whole(&h) {
+				return h.LastRecordNumber, nil
+			}
+		}
+	}
+
+	if sr == nil {
+		f, r, err := openSegment(j, seg)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		sr = r
+	}
+	for {
+		err := sr.next()
+		if err == io.EOF {
+			return sr.ID, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if filter.matches(sr.ID, sr.Timestamp) && filter.matchesData(sr.ID, sr.Timestamp, sr.Data) {
+			rec(sr.ID, sr.Timestamp)
+		}
+	}
+}
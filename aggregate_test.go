@@ -0,0 +1,38 @@
+package journal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalCount(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+	j.StartWriting()
+	ensure(j.WriteRecord(0, []byte("eleven")))
+	ensure(j.Commit())
+	must(j.Seal(context.Background()))
+
+	eq(t, must(j.Count(journal.Filter{})), 11)
+	eq(t, must(j.Count(journal.Filter{MinRecordID: 2, MaxRecordID: 9})), 8)
+	eq(t, must(j.Count(journal.Filter{MinTimestamp: at("20240101T000022000"), MaxTimestamp: at("20240101T020342000")})), 5)
+	eq(t, must(j.Count(journal.Filter{MinRecordID: 20})), 0)
+	eq(t, must(j.Count(journal.Filter{DataPrefix: []byte("t")})), 3)
+
+	buckets := must(j.Histogram(journal.Filter{}, time.Hour))
+	deepEq(t, buckets, []journal.Bucket{
+		{Start: journal.ToTime(at("20240101T000000000")), Count: 7},
+		{Start: journal.ToTime(at("20240101T010000000")), Count: 1},
+		{Start: journal.ToTime(at("20240101T020000000")), Count: 1},
+		{Start: journal.ToTime(at("20240101T030000000")), Count: 1},
+		{Start: journal.ToTime(at("20240101T040000000")), Count: 1},
+	})
+	buckets = must(j.Histogram(journal.Filter{MaxRecordID: 6}, time.Minute))
+	deepEq(t, buckets, []journal.Bucket{
+		{Start: journal.ToTime(at("20240101T000000000")), Count: 5},
+		{Start: journal.ToTime(at("20240101T000200000")), Count: 1},
+	})
+}
@@ -0,0 +1,62 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalAll(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+
+	var got []string
+	for rec, err := range j.Journal.All(journal.Filter{MinRecordID: 8}) {
+		ensure(err)
+		got = append(got, string(rec.Data))
+	}
+	deepEq(t, got, []string{"eight", "nine", "ten"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got = nil
+	var final error
+	for rec, err := range j.AllContext(ctx, journal.Filter{}) {
+		if err != nil {
+			final = err
+			continue
+		}
+		got = append(got, string(rec.Data))
+		if len(got) == 2 {
+			cancel()
+		}
+	}
+	deepEq(t, got, []string{"one", "two"})
+	eq(t, final, context.Canceled)
+
+	k := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	ensure(k.WriteRecord(0, []byte("x")))
+	ensure(k.FinishWriting())
+	journals := map[uint64]*journal.Journal{1: j.Journal, 2: k.Journal}
+	got = nil
+	for rec, err := range journal.MergedAll(journals, journal.Filter{MaxRecordID: 2}) {
+		ensure(err)
+		got = append(got, string(rec.Data))
+	}
+	deepEq(t, got, []string{"one", "x", "two"})
+
+	// errors come last
+	ensure(os.WriteFile(filepath.Join(k.Dir, k.FileNames()[0]), []byte("garbage"), 0o644))
+	k2 := open(t, k.clock, k.Dir, journal.Options{}, nonVerbose)
+	journals[2] = k2.Journal
+	final = nil
+	for _, err := range journal.MergedAllContext(context.Background(), journals, journal.Filter{}) {
+		ok(t, final == nil)
+		final = err
+	}
+	ok(t, final != nil && !errors.Is(final, context.Canceled))
+}
@@ -0,0 +1,56 @@
+package journal
+
+import "sync"
+
+const arenaChunkSize = 64 * 1024
+
+var arenaChunks = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, arenaChunkSize)
+		return &b
+	},
+}
+
+// Arena holds copies of record data made by cursors (see Filter.Arena),
+// carving them out of pooled chunks instead of allocating each one. The
+// copies stay valid until Release. An Arena must not be used concurrently.
+type Arena struct {
+	chunks []*[]byte
+}
+
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Copy returns a copy of data that is valid until Release.
+func (a *Arena) Copy(data []byte) []byte {
+	n := len(data)
+	if n == 0 {
+		return nil
+	}
+	if n > arenaChunkSize/4 {
+		return append([]byte(nil), data...)
+	}
+	var cur *[]byte
+	if k := len(a.chunks); k > 0 {
+		cur = a.chunks[k-1]
+	}
+	if cur == nil || cap(*cur)-len(*cur) < n {
+		cur = arenaChunks.Get().(*[]byte)
+		a.chunks = append(a.chunks, cur)
+	}
+	start := len(*cur)
+	*cur = append(*cur, data...)
+	return (*cur)[start : start+n : start+n]
+}
+
+// Release returns the memory of the arena to the pool. Data handed out by
+// the arena must not be used afterwards. The arena can be reused.
+func (a *Arena) Release() {
+	for i, chunk := range a.chunks {
+		*chunk = (*chunk)[:0]
+		arenaChunks.Put(chunk)
+		a.chunks[i] = nil
+	}
+	a.chunks = a.chunks[:0]
+}
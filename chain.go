@@ -0,0 +1,132 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/andreyvit/journal/segfile"
+)
+
+// ChainBreakError describes the first place where VerifyChain found the hash
+// chain or the segment/record numbering to be broken.
+type ChainBreakError struct {
+	Segment  Segment
+	RecordID uint64 // zero if the break is not tied to a specific record
+	Reason   string
+	Cause    error
+}
+
+func (e *ChainBreakError) Error() string {
+	var rec string
+	if e.RecordID != 0 {
+		rec = fmt.Sprintf(" record %d", e.RecordID)
+	}
+	if e.Cause != nil {
+		return fmt.Sprintf("journal hash chain broken at segment %v%s: %s: %v", e.Segment, rec, e.Reason, e.Cause)
+	}
+	return fmt.Sprintf("journal hash chain broken at segment %v%s: %s", e.Segment, rec, e.Reason)
+}
+
+func (e *ChainBreakError) Unwrap() error {
+	return e.Cause
+}
+
+// VerifyChain walks all sealed and unsealed segments, recomputing the hash
+// chain of segments written with Options.HashChain, and checks that segment
+// and record numbers are contiguous. It returns a *ChainBreakError describing
+// the first break found, or nil if the chain is intact.
+//
+// The oldest chained segment is trusted as the anchor of the chain, because
+// older segments may have been removed legitimately. Segments written
+// without HashChain before the first chained one are skipped.
+func (j *Journal) VerifyChain(ctx context.Context) error {
+	segs, err := j.FindSegments(Filter{})
+	if err != nil {
+		return err
+	}
+
+	var started bool
+	var prevSeg Segment
+	var prevLastRec uint64
+	var prevChain [segfile.ChainHashSize]byte
+	for _, seg := range segs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		f, sr, err := openSegment(j, seg)
+		if err == errFileGone {
+			return &ChainBreakError{Segment: seg, Reason: "segment file is gone", Cause: err}
+		} else if err != nil {
+			if isSegmentCorruptionError(err) {
+				return &ChainBreakError{Segment: seg, Reason: "cannot open segment", Cause: err}
+			}
+			return err
+		}
+
+		err = verifySegmentChain(ctx, sr, started, prevSeg, prevLastRec, prevChain)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if sr.h.Features&segfile.FeatureHashChain == 0 {
+			continue
+		}
+		started = true
+		prevSeg = seg
+		prevLastRec = sr.ID
+		prevChain = sr.Chain
+	}
+	return nil
+}
+
+func verifySegmentChain(ctx context.Context, sr *segmentReader, started bool, prevSeg Segment, prevLastRec uint64, prevChain [segfile.ChainHashSize]byte) error {
+	seg := sr.seg
+	if sr.h.Features&segfile.FeatureHashChain == 0 {
+		if started {
+			return &ChainBreakError{Segment: seg, Reason: "segment is not chained"}
+		}
+		return nil
+	}
+	if started {
+		if seg.segnum != prevSeg.segnum+1 {
+			return &ChainBreakError{Segment: seg, Reason: fmt.Sprintf("segment number gap after segment %d", prevSeg.segnum)}
+		}
+		if seg.recnum != prevLastRec+1 {
+			return &ChainBreakError{Segment: seg, RecordID: seg.recnum, Reason: fmt.Sprintf("record number gap after record %d", prevLastRec)}
+		}
+		if sr.ext.PrevChainHash != prevChain {
+			return &ChainBreakError{Segment: seg, Reason: "previous segment hash mismatch"}
+		}
+	}
+
+	var count int
+	for {
+		err := sr.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			if isSegmentCorruptionError(err) {
+				return &ChainBreakError{Segment: seg, RecordID: sr.ID + 1, Reason: "cannot read record", Cause: err}
+			}
+			return err
+		}
+		count++
+		if count%100 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !seg.status.IsDraft() {
+		if sr.h.LastRecordNumber != sr.ID {
+			return &ChainBreakError{Segment: seg, RecordID: sr.ID, Reason: fmt.Sprintf("segment ends at record %d, header says %d", sr.ID, sr.h.LastRecordNumber)}
+		}
+		if sr.ext.FinalChainHash != sr.Chain {
+			return &ChainBreakError{Segment: seg, RecordID: sr.ID, Reason: "final segment hash mismatch"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/journal"
+	"github.com/andreyvit/sealer"
+)
+
+func TestJournalVerifyChain(t *testing.T) {
+	ctx := context.Background()
+	j := setupWritable(t, newClock(), journal.Options{
+		MaxFileSize: 165 + 64, // room for the chain extension
+		HashChain:   true,
+	})
+	writeSeq(j)
+	success(t, j.VerifyChain(ctx))
+
+	// continuing a chained draft after reopening
+	j2 := open(t, j.clock, j.Dir, journal.Options{MaxFileSize: 165 + 64, HashChain: true})
+	ensure(j2.WriteRecord(0, []byte("eleven")))
+	ensure(j2.Rotate())
+	ensure(j2.WriteRecord(0, []byte("twelve")))
+	ensure(j2.FinishWriting())
+	success(t, j2.VerifyChain(ctx))
+
+	must(j2.SealAndTrimOnce(ctx))
+	must(j2.SealAndTrimOnce(ctx))
+	success(t, j2.VerifyChain(ctx))
+	recsEq(t, j2.All(journal.Filter{MaxRecordID: 4}), 1,
+		"20240101T000000000:one",
+		"20240101T000001000:two",
+		"20240101T000002000:three",
+		"20240101T000012000:four")
+
+	// removing a segment
+	name := "jF0000000003-20240101T000022000-000000000005.wal"
+	dir := t.TempDir()
+	move(j2.Dir, dir, name)
+	var cbe *journal.ChainBreakError
+	ok(t, errors.As(j2.VerifyChain(ctx), &cbe))
+	eq(t, cbe.Segment.SegmentNumber(), 3) // known but gone
+	fresh := journal.New(j2.Dir, journal.Options{
+		FileName: "j*.wal",
+		SealKeys: []*sealer.Key{sealKey},
+		Logger:   testLogger(t),
+	})
+	ok(t, errors.As(fresh.VerifyChain(ctx), &cbe))
+	eq(t, cbe.Segment.SegmentNumber(), 4)
+
+	// rewriting a segment
+	move(dir, j2.Dir, name)
+	success(t, j2.VerifyChain(ctx))
+	path := filepath.Join(j2.Dir, name)
+	b := must(os.ReadFile(path))
+	b[len(b)-10] ^= 0x20 // inside "six"
+	ensure(os.WriteFile(path, b, 0o644))
+	ok(t, errors.As(j2.VerifyChain(ctx), &cbe))
+	eq(t, cbe.Segment.SegmentNumber(), 3)
+}
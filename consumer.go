@@ -0,0 +1,198 @@
+package journal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+var errCorruptedOffsets = errors.New("corrupted journal consumer offsets")
+
+// Consumer offsets file format:
+//
+//   - file = magic:64 consumerEntry* checksum:64
+//   - consumerEntry = acked:64 nameLen:8 name
+
+const magicV1Offsets = uint64('J')<<0 | uint64('O')<<8 | uint64('U')<<16 | uint64('R')<<24 | uint64('N')<<32 | uint64('L')<<40 | uint64('A')<<48 | uint64('C')<<56
+
+const (
+	offsetsFileName    = "offsets"
+	offsetsTempName    = "offsets-temp"
+	maxConsumerNameLen = 255
+)
+
+type consumerStore struct {
+	lock    sync.Mutex
+	loaded  bool
+	offsets map[string]uint64
+}
+
+// Consumer durably tracks the last record processed by a named reader of
+// the journal. See Journal.Consumer.
+type Consumer struct {
+	j    *Journal
+	name string
+}
+
+// Consumer returns the consumer with the given name, registering it if
+// necessary. A newly registered consumer hasn't acknowledged any records.
+// With Options.RetainUnacknowledged, registered consumers prevent Trim from
+// deleting records they haven't acknowledged yet.
+func (j *Journal) Consumer(name string) (*Consumer, error) {
+	if name == "" || len(name) > maxConsumerNameLen {
+		return nil, fmt.Errorf("journal: invalid consumer name %q", name)
+	}
+	cs := &j.consumers
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := j.loadOffsets_locked(); err != nil {
+		return nil, err
+	}
+	if _, found := cs.offsets[name]; !found {
+		offsets := maps.Clone(cs.offsets)
+		offsets[name] = 0
+		if err := j.saveOffsets_locked(offsets); err != nil {
+			return nil, err
+		}
+	}
+	return &Consumer{j: j, name: name}, nil
+}
+
+// Consumers returns the names of the registered consumers.
+func (j *Journal) Consumers() ([]string, error) {
+	cs := &j.consumers
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := j.loadOffsets_locked(); err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(cs.offsets)), nil
+}
+
+func (c *Consumer) Name() string {
+	return c.name
+}
+
+// Committed returns the ID of the last acknowledged record, or 0 if none.
+func (c *Consumer) Committed() (uint64, error) {
+	cs := &c.j.consumers
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := c.j.loadOffsets_locked(); err != nil {
+		return 0, err
+	}
+	return cs.offsets[c.name], nil
+}
+
+// Ack durably records that the consumer has processed all records up to and
+// including id. Acknowledging an earlier record than Committed does nothing.
+func (c *Consumer) Ack(id uint64) error {
+	cs := &c.j.consumers
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := c.j.loadOffsets_locked(); err != nil {
+		return err
+	}
+	if acked, found := cs.offsets[c.name]; found && id <= acked {
+		return nil
+	}
+	offsets := maps.Clone(cs.offsets)
+	offsets[c.name] = id
+	return c.j.saveOffsets_locked(offsets)
+}
+
+// Remove unregisters the consumer. Using the consumer afterwards registers
+// it again.
+func (c *Consumer) Remove() error {
+	cs := &c.j.consumers
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := c.j.loadOffsets_locked(); err != nil {
+		return err
+	}
+	if _, found := cs.offsets[c.name]; !found {
+		return nil
+	}
+	offsets := maps.Clone(cs.offsets)
+	delete(offsets, c.name)
+	return c.j.saveOffsets_locked(offsets)
+}
+
+// minAcknowledged returns the smallest acknowledged record ID among the
+// registered consumers; ok is false if there are none.
+func (j *Journal) minAcknowledged() (acked uint64, ok bool, err error) {
+	cs := &j.consumers
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := j.loadOffsets_locked(); err != nil {
+		return 0, false, err
+	}
+	for _, id := range cs.offsets {
+		if !ok || id < acked {
+			acked, ok = id, true
+		}
+	}
+	return acked, ok, nil
+}
+
+func (j *Journal) offsetsPath() string {
+	return j.metaFilePath(offsetsFileName)
+}
+
+func (j *Journal) loadOffsets_locked() error {
+	cs := &j.consumers
+	if cs.loaded {
+		return nil
+	}
+	b, err := os.ReadFile(j.offsetsPath())
+	if os.IsNotExist(err) {
+		cs.offsets = make(map[string]uint64)
+		cs.loaded = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	corrupted := func() error {
+		j.logger.Error("journal consumer offsets are corrupted", "journal", j.debugName, "path", j.offsetsPath())
+		return errCorruptedOffsets
+	}
+	if len(b) < 16 || binary.LittleEndian.Uint64(b) != magicV1Offsets || binary.LittleEndian.Uint64(b[len(b)-8:]) != xxhash.Sum64(b[:len(b)-8]) {
+		return corrupted()
+	}
+	offsets := make(map[string]uint64)
+	for p := b[8 : len(b)-8]; len(p) > 0; {
+		if len(p) < 9 || len(p) < 9+int(p[8]) {
+			return corrupted()
+		}
+		n := 9 + int(p[8])
+		offsets[string(p[9:n])] = binary.LittleEndian.Uint64(p)
+		p = p[n:]
+	}
+	cs.offsets = offsets
+	cs.loaded = true
+	return nil
+}
+
+func (j *Journal) saveOffsets_locked(offsets map[string]uint64) error {
+	b := binary.LittleEndian.AppendUint64(nil, magicV1Offsets)
+	for _, name := range slices.Sorted(maps.Keys(offsets)) {
+		b = binary.LittleEndian.AppendUint64(b, offsets[name])
+		b = append(b, byte(len(name)))
+		b = append(b, name...)
+	}
+	b = binary.LittleEndian.AppendUint64(b, xxhash.Sum64(b))
+
+	err := writeFileAtomically(j.metaFilePath(offsetsTempName), j.offsetsPath(), b)
+	if err != nil {
+		return err
+	}
+	j.consumers.offsets = offsets
+	return nil
+}
@@ -0,0 +1,75 @@
+package journal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalConsumer(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	a := must(j.Consumer("indexer"))
+	eq(t, must(a.Committed()), 0)
+	ensure(a.Ack(5))
+	ensure(a.Ack(3)) // ignored
+	eq(t, must(a.Committed()), 5)
+	must(j.Consumer("mailer"))
+
+	k := open(t, j.clock, j.Dir, journal.Options{})
+	deepEq(t, must(k.Consumers()), []string{"indexer", "mailer"})
+	eq(t, must(must(k.Consumer("indexer")).Committed()), 5)
+	eq(t, must(must(k.Consumer("mailer")).Committed()), 0)
+
+	ensure(must(k.Consumer("mailer")).Remove())
+	deepEq(t, must(k.Consumers()), []string{"indexer"})
+
+	_, err := j.Consumer("")
+	ok(t, err != nil)
+}
+
+func TestJournalConsumer_retain(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{RetainUnacknowledged: true})
+	writeSeq(j)
+	j.StartWriting()
+	c := must(j.Consumer("indexer"))
+
+	must(j.SealAndTrimAll(context.Background()))
+	deepEq(t, j.FileNames(), []string{
+		"jF0000000001-20240101T000000000-000000000001.wal",
+		"jS0000000001-20240101T000000000-000000000001.wal",
+		"jF0000000002-20240101T000002000-000000000003.wal",
+		"jS0000000002-20240101T000002000-000000000003.wal",
+		"jF0000000003-20240101T000022000-000000000005.wal",
+		"jS0000000003-20240101T000022000-000000000005.wal",
+		"jF0000000004-20240101T000342000-000000000007.wal",
+		"jS0000000004-20240101T000342000-000000000007.wal",
+		"jW0000000005-20240101T020342000-000000000009.wal",
+		".joffsets.wal",
+	})
+
+	ensure(c.Ack(3)) // segment 2 still has an unacknowledged record
+	must(j.SealAndTrimAll(context.Background()))
+	deepEq(t, j.FileNames(), []string{
+		"jS0000000001-20240101T000000000-000000000001.wal",
+		"jF0000000002-20240101T000002000-000000000003.wal",
+		"jS0000000002-20240101T000002000-000000000003.wal",
+		"jF0000000003-20240101T000022000-000000000005.wal",
+		"jS0000000003-20240101T000022000-000000000005.wal",
+		"jF0000000004-20240101T000342000-000000000007.wal",
+		"jS0000000004-20240101T000342000-000000000007.wal",
+		"jW0000000005-20240101T020342000-000000000009.wal",
+		".joffsets.wal",
+	})
+
+	ensure(c.Remove())
+	must(j.SealAndTrimAll(context.Background()))
+	deepEq(t, j.FileNames(), []string{
+		"jS0000000001-20240101T000000000-000000000001.wal",
+		"jS0000000002-20240101T000002000-000000000003.wal",
+		"jS0000000003-20240101T000022000-000000000005.wal",
+		"jS0000000004-20240101T000342000-000000000007.wal",
+		"jW0000000005-20240101T020342000-000000000009.wal",
+		".joffsets.wal",
+	})
+}
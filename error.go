@@ -13,3 +13,19 @@ func (e *fsyncFailedError) Error() string {
 func (e *fsyncFailedError) Unwrap() error {
 	return e.Cause
 }
+
+// SegmentInvariantError is returned when a segment header records a segment
+// invariant that is neither Options.SegmentInvariant nor accepted by
+// Options.AcceptSegmentInvariant.
+type SegmentInvariantError struct {
+	Segment   Segment
+	Invariant [32]byte
+}
+
+func (e *SegmentInvariantError) Error() string {
+	return fmt.Sprintf("incompatible journal segment %v: unaccepted segment invariant %x", e.Segment, e.Invariant)
+}
+
+func (e *SegmentInvariantError) Unwrap() error {
+	return ErrIncompatible
+}
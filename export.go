@@ -0,0 +1,237 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+type ExportFormat int
+
+const (
+	// JSONLines exports one JSON object per line:
+	//
+	//	{"id":1,"timestamp":"2024-01-01T00:00:00Z","text":"..."}
+	//
+	// Records that are not valid UTF-8 have a base64 "data" field instead
+	// of "text".
+	JSONLines ExportFormat = iota + 1
+
+	// CSV exports a header row followed by id,timestamp,text,data rows,
+	// with data (base64) filled in instead of text for records that are
+	// not valid UTF-8 or contain carriage returns, which CSV readers
+	// normalize.
+	CSV
+)
+
+// importBatchSize is the number of imported records per commit.
+const importBatchSize = 1000
+
+var csvHeader = []string{"id", "timestamp", "text", "data"}
+
+var ErrInvalidImport = errors.New("invalid journal import data")
+
+type exportedRecord struct {
+	ID        uint64  `json:"id"`
+	Timestamp string  `json:"timestamp"`
+	Text      *string `json:"text,omitempty"`
+	Data      []byte  `json:"data,omitempty"`
+}
+
+func formatExportTime(ts uint64) string {
+	return ToTime(ts).UTC().Format(time.RFC3339Nano)
+}
+
+func parseExportTime(s string) (uint64, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, err
+	}
+	if t.UnixMilli() < 0 {
+		return 0, fmt.Errorf("timestamp %s before 1970", s)
+	}
+	return ToTimestamp(t), nil
+}
+
+// Export writes the records matching the filter to w in the given format.
+func (j *Journal) Export(w io.Writer, filter Filter, format ExportFormat) error {
+	bw := bufio.NewWriter(w)
+	c := j.Read(filter)
+	defer c.Close()
+
+	switch format {
+	case JSONLines:
+		enc := json.NewEncoder(bw)
+		for c.Next() {
+			er := exportedRecord{ID: c.ID, Timestamp: formatExportTime(c.Timestamp)}
+			if utf8.Valid(c.Data) {
+				text := string(c.Data)
+				er.Text = &text
+			} else {
+				er.Data = c.Data
+			}
+			if err := enc.Encode(&er); err != nil {
+				return err
+			}
+		}
+	case CSV:
+		cw := csv.NewWriter(bw)
+		cw.Write(csvHeader)
+		row := make([]string, len(csvHeader))
+		for c.Next() {
+			row[0] = strconv.FormatUint(c.ID, 10)
+			row[1] = formatExportTime(c.Timestamp)
+			if utf8.Valid(c.Data) && bytes.IndexByte(c.Data, '\r') < 0 {
+				row[2], row[3] = string(c.Data), ""
+			} else {
+				row[2], row[3] = "", base64.StdEncoding.EncodeToString(c.Data)
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("journal: unknown export format %d", format)
+	}
+	if err := c.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Import appends the records read from r, which holds the output of Export
+// in either format, preserving their timestamps. Records get new IDs, and,
+// like with WriteRecord, timestamps earlier than the last record's one are
+// raised to it. Records with empty data or a zero timestamp cannot be
+// written as they are, and are rejected with ErrInvalidImport.
+//
+// Records are committed in batches; a batch is parsed and validated in full
+// before it is written, so invalid input never leaves a partial batch
+// behind. Returns the number of imported (committed) records.
+//
+// A write error fails the journal writer, as with WriteRecord, so the
+// records of the failed batch written so far are never committed; except
+// that those preceding a segment rotation in the middle of the batch have
+// been committed by the rotation, and are not included in the count.
+func (j *Journal) Import(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+	var next func() (Record, error)
+	if isJSONInput(br) {
+		dec := json.NewDecoder(br)
+		next = func() (Record, error) {
+			var er exportedRecord
+			err := dec.Decode(&er)
+			if err != nil {
+				if err != io.EOF {
+					err = fmt.Errorf("%w: %v", ErrInvalidImport, err)
+				}
+				return Record{}, err
+			}
+			ts, err := parseExportTime(er.Timestamp)
+			if err != nil {
+				return Record{}, fmt.Errorf("%w: record %d: %v", ErrInvalidImport, er.ID, err)
+			}
+			rec := Record{ID: er.ID, Timestamp: ts, Data: er.Data}
+			if er.Text != nil {
+				rec.Data = []byte(*er.Text)
+			}
+			return rec, nil
+		}
+	} else {
+		cr := csv.NewReader(br)
+		cr.FieldsPerRecord = len(csvHeader)
+		header, err := cr.Read()
+		if err == io.EOF {
+			return 0, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if !slices.Equal(header, csvHeader) {
+			return 0, fmt.Errorf("%w: unexpected CSV header %q", ErrInvalidImport, header)
+		}
+		next = func() (Record, error) {
+			row, err := cr.Read()
+			if err != nil {
+				if err != io.EOF {
+					err = fmt.Errorf("%w: %v", ErrInvalidImport, err)
+				}
+				return Record{}, err
+			}
+			id, err := strconv.ParseUint(row[0], 10, 64)
+			if err != nil {
+				return Record{}, fmt.Errorf("%w: id %q", ErrInvalidImport, row[0])
+			}
+			ts, err := parseExportTime(row[1])
+			if err != nil {
+				return Record{}, fmt.Errorf("%w: record %d: %v", ErrInvalidImport, id, err)
+			}
+			rec := Record{ID: id, Timestamp: ts, Data: []byte(row[2])}
+			if row[3] != "" {
+				rec.Data, err = base64.StdEncoding.DecodeString(row[3])
+				if err != nil {
+					return Record{}, fmt.Errorf("%w: record %d: %v", ErrInvalidImport, id, err)
+				}
+			}
+			return rec, nil
+		}
+	}
+
+	var count int
+	batch := make([]Record, 0, importBatchSize)
+	for {
+		rec, err := next()
+		if err != nil && err != io.EOF {
+			return count, err
+		}
+		if err == nil {
+			if len(rec.Data) == 0 {
+				return count, fmt.Errorf("%w: record %d: empty data", ErrInvalidImport, rec.ID)
+			}
+			if rec.Timestamp == 0 {
+				return count, fmt.Errorf("%w: record %d: zero timestamp", ErrInvalidImport, rec.ID)
+			}
+			batch = append(batch, rec)
+		}
+		if len(batch) == importBatchSize || (err == io.EOF && len(batch) > 0) {
+			for _, rec := range batch {
+				if err := j.WriteRecord(rec.Timestamp, rec.Data); err != nil {
+					return count, err
+				}
+			}
+			if err := j.Commit(); err != nil {
+				return count, err
+			}
+			count += len(batch)
+			batch = batch[:0]
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+	}
+}
+
+func isJSONInput(br *bufio.Reader) bool {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0] == '{'
+		}
+	}
+}
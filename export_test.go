@@ -0,0 +1,87 @@
+package journal_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalExportImport(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	ensure(j.WriteRecord(0, []byte(`hello, "world"`)))
+	j.clock.Advance(1500 * time.Millisecond)
+	ensure(j.WriteRecord(0, []byte{0xFF, 0x00}))
+	j.clock.Advance(time.Second)
+	ensure(j.WriteRecord(0, []byte("three")))
+	ensure(j.Commit())
+
+	var buf bytes.Buffer
+	ensure(j.Export(&buf, journal.Filter{}, journal.JSONLines))
+	eq(t, buf.String(), ""+
+		`{"id":1,"timestamp":"2024-01-01T00:00:00Z","text":"hello, \"world\""}`+"\n"+
+		`{"id":2,"timestamp":"2024-01-01T00:00:01.5Z","data":"/wA="}`+"\n"+
+		`{"id":3,"timestamp":"2024-01-01T00:00:02.5Z","text":"three"}`+"\n")
+	jsonl := buf.String()
+
+	buf.Reset()
+	ensure(j.Export(&buf, journal.Filter{MinRecordID: 2}, journal.CSV))
+	eq(t, buf.String(), ""+
+		"id,timestamp,text,data\n"+
+		"2,2024-01-01T00:00:01.5Z,,/wA=\n"+
+		"3,2024-01-01T00:00:02.5Z,three,\n")
+	csv := buf.String()
+
+	k := setupWritable(t, newClock(), journal.Options{})
+	eq(t, must(k.Import(strings.NewReader(jsonl))), 3)
+	eq(t, must(k.Import(strings.NewReader(csv))), 2)
+	recsEq(t, k.All(journal.Filter{}), 1,
+		`20240101T000000000:hello, "world"`,
+		"20240101T000001500:\xFF\x00",
+		"20240101T000002500:three",
+		"20240101T000002500:\xFF\x00",
+		"20240101T000002500:three")
+
+	// CSV rows survive line breaks and binary data
+	m := setupWritable(t, newClock(), journal.Options{})
+	ensure(m.WriteRecord(0, []byte("a\r\nb")))
+	ensure(m.WriteRecord(0, []byte("c\nd")))
+	ensure(m.WriteRecord(0, []byte{0xFF, '\r', '\n', 0x00}))
+	ensure(m.Commit())
+	buf.Reset()
+	ensure(m.Export(&buf, journal.Filter{}, journal.CSV))
+	eq(t, buf.String(), ""+
+		"id,timestamp,text,data\n"+
+		"1,2024-01-01T00:00:00Z,,YQ0KYg==\n"+
+		"2,2024-01-01T00:00:00Z,\"c\nd\",\n"+
+		"3,2024-01-01T00:00:00Z,,/w0KAA==\n")
+	m2 := setupWritable(t, newClock(), journal.Options{})
+	eq(t, must(m2.Import(&buf)), 3)
+	recsEq(t, m2.All(journal.Filter{}), 1,
+		"20240101T000000000:a\r\nb",
+		"20240101T000000000:c\nd",
+		"20240101T000000000:\xFF\r\n\x00")
+
+	// invalid input is rejected before writing the batch
+	n, err := k.Import(strings.NewReader(jsonl + "{\"id\":4,\"timestamp\":\"yesterday\"}\n"))
+	eq(t, n, 0)
+	ok(t, errors.Is(err, journal.ErrInvalidImport))
+	eq(t, len(k.All(journal.Filter{})), 5)
+
+	// records that would not be written as they are
+	for _, line := range []string{
+		`{"id":4,"timestamp":"2024-01-01T00:00:00Z","text":""}`,
+		`{"id":4,"timestamp":"1970-01-01T00:00:00Z","text":"epoch"}`,
+	} {
+		n, err = k.Import(strings.NewReader(jsonl + line + "\n"))
+		eq(t, n, 0)
+		ok(t, errors.Is(err, journal.ErrInvalidImport))
+	}
+	n, err = k.Import(strings.NewReader("id,timestamp,text,data\n4,2024-01-01T00:00:00Z,,\n"))
+	eq(t, n, 0)
+	ok(t, errors.Is(err, journal.ErrInvalidImport))
+	eq(t, len(k.All(journal.Filter{})), 5)
+}
@@ -1,12 +1,100 @@
 package journal
 
+import "bytes"
+
 type Filter struct {
 	MinRecordID  uint64
 	MinTimestamp uint64
 	MaxRecordID  uint64
 	MaxTimestamp uint64
-	Limit        int
-	Latest       bool
+
+	// Limit caps the number of returned records, counting from the first
+	// matching one (from the newest one with Latest, or for ReadReverse).
+	Limit int
+
+	// Latest makes Limit select the newest matching records, which are
+	// still returned from oldest to newest.
+	Latest bool
+
+	// Offset skips this many matching records before Limit is counted.
+	Offset int
+
+	// CopyData makes cursors return a fresh copy of each record's data
+	// instead of a slice that is only valid until the next call to Next.
+	CopyData bool
+
+	// Arena, if set, makes cursors copy each record's data into the arena,
+	// where it stays valid until Arena.Release. Takes precedence over
+	// CopyData.
+	Arena *Arena
+
+	// Prefetch, if positive, makes forward cursors decode up to this many
+	// upcoming sealed segments in background goroutines, while still
+	// returning records in order. Decoded segments are held in memory until
+	// their records are returned. Ignored by Follow and ReadReverse.
+	Prefetch int
+
+	// DataPrefix, if non-nil, only matches records whose data starts with
+	// it.
+	DataPrefix []byte
+
+	// Match, if set, only matches records for which it returns true. The
+	// record's data is only valid during the call. Match is applied after
+	// all other conditions, and before Limit is counted.
+	Match func(rec Record) bool
+
+	// OnSegmentError, if set, decides what a cursor does when a segment
+	// cannot be read: because it is corrupted, gone, or sealed with a key
+	// that isn't available. Records delivered before the error stay
+	// delivered; see Cursor.Skipped for the records that were not.
+	OnSegmentError func(seg Segment, err error) Action
+
+	// FailOnGap makes cursors fail with ErrGap when the record IDs jump
+	// between adjacent segments, e.g. because a segment file has been
+	// deleted, instead of silently moving on to the next records.
+	FailOnGap bool
+}
+
+// ownData returns data as requested by CopyData and Arena.
+func (f *Filter) ownData(data []byte) []byte {
+	if f.Arena != nil {
+		return f.Arena.Copy(data)
+	} else if f.CopyData {
+		return bytes.Clone(data)
+	}
+	return data
+}
+
+// hasContentFilter tells whether matching depends on the data of records.
+func (f *Filter) hasContentFilter() bool {
+	return f.DataPrefix != nil || f.Match != nil
+}
+
+// matchesData applies DataPrefix and Match.
+func (f *Filter) matchesData(id, ts uint64, data []byte) bool {
+	if f.DataPrefix != nil && !bytes.HasPrefix(data, f.DataPrefix) {
+		return false
+	}
+	if f.Match != nil && !f.Match(Record{ID: id, Timestamp: ts, Data: data}) {
+		return false
+	}
+	return true
+}
+
+func (f *Filter) matches(id, ts uint64) bool {
+	if f.MinRecordID != 0 && id < f.MinRecordID {
+		return false
+	}
+	if f.MaxRecordID != 0 && id > f.MaxRecordID {
+		return false
+	}
+	if f.MinTimestamp != 0 && ts < f.MinTimestamp {
+		return false
+	}
+	if f.MaxTimestamp != 0 && ts > f.MaxTimestamp {
+		return false
+	}
+	return true
 }
 
 // type collectSegmentsSession struct {
@@ -0,0 +1,43 @@
+package journal_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalRead_contentFilters(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{MaxFileSize: 165}, nonVerbose)
+	for i := 1; i <= 12; i++ {
+		tenant := "a"
+		if i%3 == 0 {
+			tenant = "b"
+		}
+		ensure(j.WriteRecord(0, []byte(fmt.Sprintf("%s/%d", tenant, i))))
+		ensure(j.Commit())
+	}
+	must(j.SealAndTrimOnce(context.Background()))
+
+	tenantB := journal.Filter{DataPrefix: []byte("b/")}
+	deepEq(t, collect(t, j.Read(tenantB)), []string{"3:b/3", "6:b/6", "9:b/9", "12:b/12"})
+
+	tenantB.Limit = 2
+	deepEq(t, collect(t, j.Read(tenantB)), []string{"3:b/3", "6:b/6"})
+	tenantB.Prefetch = 2
+	deepEq(t, collect(t, j.Read(tenantB)), []string{"3:b/3", "6:b/6"})
+	tenantB.Latest = true
+	deepEq(t, collect(t, j.Read(tenantB)), []string{"9:b/9", "12:b/12"})
+	deepEq(t, collect(t, j.ReadReverse(tenantB)), []string{"12:b/12", "9:b/9"})
+
+	even := journal.Filter{
+		MinRecordID: 3,
+		Limit:       3,
+		Match: func(rec journal.Record) bool {
+			return rec.ID%2 == 0 && !bytes.HasSuffix(rec.Data, []byte("/6"))
+		},
+	}
+	deepEq(t, collect(t, j.Read(even)), []string{"4:a/4", "8:a/8", "10:a/10"})
+}
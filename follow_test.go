@@ -0,0 +1,72 @@
+package journal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalFollow(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{MaxFileSize: 165}, nonVerbose)
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.Commit())
+	ensure(j.WriteRecord(0, []byte("two")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recs := make(chan string)
+	done := make(chan error)
+	go func() {
+		for rec, err := range j.FollowRecords(ctx, journal.Filter{MinRecordID: 1}) {
+			if err != nil {
+				done <- err
+				return
+			}
+			recs <- fmt.Sprintf("%d:%s", rec.ID, rec.Data)
+		}
+		done <- nil
+	}()
+	expect := func(e string) {
+		t.Helper()
+		select {
+		case a := <-recs:
+			eq(t, a, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", e)
+		}
+	}
+
+	expect("1:one")
+	select {
+	case a := <-recs:
+		t.Fatalf("uncommitted record yielded: %s", a)
+	case <-time.After(20 * time.Millisecond):
+	}
+	ensure(j.Commit())
+	expect("2:two")
+
+	// across rotations, sealing and trimming
+	for i, s := range []string{"three", "four", "five", "six"} {
+		ensure(j.WriteRecord(0, []byte(s)))
+		ensure(j.Commit())
+		expect(fmt.Sprintf("%d:%s", i+3, s))
+		if i == 1 {
+			must(j.SealAndTrimAll(context.Background()))
+		}
+	}
+	ok(t, len(j.Segments()) > 1)
+
+	cancel()
+	eq(t, <-done, context.Canceled)
+
+	// a bounded filter ends following
+	var got []string
+	for rec, err := range j.FollowRecords(context.Background(), journal.Filter{MinRecordID: 5, MaxRecordID: 6}) {
+		ensure(err)
+		got = append(got, string(rec.Data))
+	}
+	deepEq(t, got, []string{"five", "six"})
+}
@@ -0,0 +1,120 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/andreyvit/journal/segfile"
+)
+
+var ErrGap = errors.New("journal records are missing")
+
+// Gap describes records missing between two adjacent segments, e.g. because
+// a segment file has been deleted; see Summary.Gaps and Filter.FailOnGap.
+type Gap struct {
+	PrevSegment uint64 // number of the segment preceding the gap
+	NextSegment uint64 // number of the segment following the gap
+	MinRecordID uint64 // 0 if unknown, i.e. the preceding segment is a draft
+	MaxRecordID uint64
+}
+
+func (g Gap) String() string {
+	if g.MinRecordID == 0 {
+		return fmt.Sprintf("..%d between segments %d and %d", g.MaxRecordID, g.PrevSegment, g.NextSegment)
+	}
+	return fmt.Sprintf("%d..%d between segments %d and %d", g.MinRecordID, g.MaxRecordID, g.PrevSegment, g.NextSegment)
+}
+
+// noLastID marks segments whose header cannot be read in
+// journalState.lastIDs, so that they aren't retried on every call.
+const noLastID = math.MaxUint64
+
+// gaps finds the records missing between adjacent segments. The result is
+// cached until the segments change. The last record of finalized and sealed
+// segments is known from their headers, which are read outside of the state
+// lock and cached too; after a draft, only a missing segment number reveals a
+// gap.
+func (j *Journal) gaps() ([]Gap, error) {
+	j.state.lock.Lock()
+	if err := j.state.ensureInitialized(j); err != nil {
+		j.state.lock.Unlock()
+		return nil, err
+	}
+	if j.state.gapsKnown {
+		gaps := slices.Clone(j.state.gaps)
+		j.state.lock.Unlock()
+		return gaps, nil
+	}
+	gen := j.state.segmentsGen
+	segs := j.state.readableSegments()
+	lastIDs := make(map[uint64]uint64, len(segs))
+	for _, seg := range segs {
+		if id, found := j.state.lastIDs[seg.segnum]; found {
+			lastIDs[seg.segnum] = id
+		}
+	}
+	j.state.lock.Unlock()
+
+	for i := 0; i+1 < len(segs); i++ {
+		seg := segs[i]
+		if _, found := lastIDs[seg.segnum]; found || seg.status.IsDraft() {
+			continue
+		}
+		id, err := segmentLastRecordID(j, seg)
+		if err == errFileGone {
+			continue // trimmed, try its sealed copy next time
+		} else if err != nil {
+			id = noLastID
+		}
+		lastIDs[seg.segnum] = id
+	}
+
+	var gaps []Gap
+	for i := 1; i < len(segs); i++ {
+		prev, next := segs[i-1], segs[i]
+		g := Gap{PrevSegment: prev.segnum, NextSegment: next.segnum, MaxRecordID: next.recnum - 1}
+		if last, found := lastIDs[prev.segnum]; found && last != noLastID {
+			if next.recnum <= last+1 {
+				continue
+			}
+			g.MinRecordID = last + 1
+		} else if next.segnum == prev.segnum+1 {
+			continue
+		}
+		gaps = append(gaps, g)
+	}
+
+	j.state.lock.Lock()
+	j.state.lastIDs = lastIDs // forgets the segments that are gone
+	if j.state.segmentsGen == gen {
+		j.state.gaps, j.state.gapsKnown = gaps, true
+	}
+	j.state.lock.Unlock()
+	return slices.Clone(gaps), nil
+}
+
+// segmentLastRecordID returns the ID of the last record of a finalized or
+// sealed segment from its header.
+func segmentLastRecordID(j *Journal, seg Segment) (uint64, error) {
+	var h segfile.Header
+	var ext segfile.Extension
+	if err := loadSegmentHeader(j, &h, &ext, seg); err != nil {
+		return 0, err
+	}
+	if h.LastRecordNumber < h.FirstRecordNumber {
+		return seg.recnum - 1, nil // empty
+	}
+	return h.LastRecordNumber, nil
+}
+
+// gapError checks that a forward cursor moving on to seg does not skip any
+// records after the previous segment; see Filter.FailOnGap. Missing records
+// that precede MinRecordID don't matter.
+func (c *Cursor) gapError(seg Segment) error {
+	if !c.filter.FailOnGap || c.ended == 0 || seg.recnum <= c.ended+1 || seg.recnum <= c.filter.MinRecordID {
+		return nil
+	}
+	return fmt.Errorf("%w: %d..%d before segment %v", ErrGap, c.ended+1, seg.recnum-1, seg)
+}
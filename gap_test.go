@@ -0,0 +1,53 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalGaps(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+	ensure(os.Remove(filepath.Join(j.Dir, "jF0000000003-20240101T000022000-000000000005.wal")))
+
+	k := open(t, j.clock, j.Dir, journal.Options{}, nonVerbose)
+	eq(t, len(must(k.QuickSummary()).Gaps), 0) // doesn't read segment headers
+	sum := must(k.Summary())
+	deepEq(t, sum.Gaps, []journal.Gap{{PrevSegment: 2, NextSegment: 4, MinRecordID: 5, MaxRecordID: 6}})
+	deepEq(t, must(k.Summary()).Gaps, sum.Gaps) // cached
+
+	all := []string{"1:one", "2:two", "3:three", "4:four", "7:seven", "8:eight", "9:nine", "10:ten"}
+	deepEq(t, collect(t, k.Read(journal.Filter{})), all)
+
+	fails := func(c *journal.Cursor, expected []string) {
+		t.Helper()
+		defer c.Close()
+		var actual []string
+		for c.Next() {
+			actual = append(actual, string(c.Data))
+		}
+		deepEq(t, actual, expected)
+		if err := c.Err(); !errors.Is(err, journal.ErrGap) {
+			t.Errorf("Err() = %v, wanted ErrGap", err)
+		}
+	}
+	fails(k.Read(journal.Filter{FailOnGap: true}), []string{"one", "two", "three", "four"})
+	fails(k.Read(journal.Filter{FailOnGap: true, MinRecordID: 4}), []string{"four"})
+	fails(k.ReadReverse(journal.Filter{FailOnGap: true}), []string{"ten", "nine", "eight", "seven"})
+	deepEq(t, collect(t, k.Read(journal.Filter{FailOnGap: true, MinRecordID: 7})), all[4:])
+
+	// sealed segments too
+	k.StartWriting()
+	must(k.SealAndTrimAll(context.Background()))
+	fails(k.Read(journal.Filter{FailOnGap: true, Prefetch: 2}), []string{"one", "two", "three", "four"})
+	deepEq(t, must(k.Summary()).Gaps, sum.Gaps) // recomputed for the sealed segments
+
+	k = open(t, j.clock, j.Dir, journal.Options{}, nonVerbose)
+	sum = must(k.Summary())
+	deepEq(t, sum.Gaps, []journal.Gap{{PrevSegment: 2, NextSegment: 4, MinRecordID: 5, MaxRecordID: 6}})
+}
@@ -0,0 +1,212 @@
+package journal
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("journal record not found")
+
+const (
+	getCacheSize = 4               // segment readers kept open for point lookups
+	getCacheTTL  = 5 * time.Second // how long an unused reader stays open
+)
+
+// getCache keeps the segment readers of recent point lookups open for
+// a short while, so that a lookup of a later ID in the same segment
+// continues where the previous one stopped instead of rereading (and, for
+// sealed segments, decrypting) the segment from its start.
+type getCache struct {
+	lock    sync.Mutex
+	readers []*cachedReader // least recently used first
+	timer   *time.Timer
+}
+
+type cachedReader struct {
+	file io.Closer
+	sr   *segmentReader
+	used time.Time
+}
+
+// take removes and returns the cached reader of seg that is still before
+// id, if any.
+func (gc *getCache) take(seg Segment, id uint64) *cachedReader {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	for i, cr := range gc.readers {
+		if cr.sr.seg == seg && cr.sr.ID < id {
+			gc.readers = slices.Delete(gc.readers, i, i+1)
+			return cr
+		}
+	}
+	return nil
+}
+
+// put returns a reader to the cache, closing the least recently used one
+// if the cache is full.
+func (gc *getCache) put(cr *cachedReader) {
+	cr.used = time.Now()
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	if len(gc.readers) == getCacheSize {
+		gc.readers[0].file.Close()
+		gc.readers = slices.Delete(gc.readers, 0, 1)
+	}
+	gc.readers = append(gc.readers, cr)
+	if gc.timer == nil {
+		gc.timer = time.AfterFunc(getCacheTTL, gc.expire)
+	}
+}
+
+// expire closes the readers that haven't been used for getCacheTTL.
+func (gc *getCache) expire() {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	gc.timer = nil
+	cutoff := time.Now().Add(-getCacheTTL)
+	gc.readers = slices.DeleteFunc(gc.readers, func(cr *cachedReader) bool {
+		if cr.used.After(cutoff) {
+			return false
+		}
+		cr.file.Close()
+		return true
+	})
+	if len(gc.readers) > 0 {
+		gc.timer = time.AfterFunc(gc.readers[0].used.Sub(cutoff), gc.expire)
+	}
+}
+
+// clear closes all cached readers.
+func (gc *getCache) clear() {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	for _, cr := range gc.readers {
+		cr.file.Close()
+	}
+	gc.readers = nil
+}
+
+// Get returns the committed record with the given ID, or ErrNotFound if
+// there is no such record (including records that have been deleted). The
+// returned data is owned by the caller.
+func (j *Journal) Get(id uint64) (Record, error) {
+	recs, err := j.GetMany([]uint64{id})
+	if err != nil {
+		return Record{}, err
+	}
+	return recs[0], nil
+}
+
+// GetMany returns the committed records with the given IDs, in the same
+// order. The IDs are looked up in ascending order, and the segment readers
+// are kept open for a few seconds, so records that share a segment are read
+// in a single pass, also across calls. The returned data is owned by the
+// caller.
+//
+// If some records don't exist, GetMany returns an error wrapping ErrNotFound
+// along with the records that were found; the entries of the missing ones
+// are zero Records. Other errors are returned along with the records found
+// before the error.
+func (j *Journal) GetMany(ids []uint64) ([]Record, error) {
+	result := make([]Record, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	found := make(map[uint64]Record, len(sorted))
+	fill := func() (missing []uint64) {
+		for i, id := range ids {
+			rec, ok := found[id]
+			if !ok {
+				missing = append(missing, id)
+				continue
+			}
+			result[i] = rec
+		}
+		return missing
+	}
+	for _, id := range sorted {
+		rec, ok, err := j.lookup(id)
+		if err != nil {
+			fill()
+			return result, err
+		}
+		if ok {
+			found[id] = rec
+		}
+	}
+	if missing := fill(); len(missing) > 0 {
+		return result, fmt.Errorf("%w: %v", ErrNotFound, missing)
+	}
+	return result, nil
+}
+
+// lookup finds the committed record with the given ID using the segment
+// boundaries of the journal state and the cached segment readers.
+func (j *Journal) lookup(id uint64) (Record, bool, error) {
+	if id == 0 {
+		return Record{}, false, nil
+	}
+	for retried := false; ; retried = true {
+		seg, err := j.segmentContaining(id)
+		if err != nil || seg.IsZero() {
+			return Record{}, false, err
+		}
+		cr := j.gets.take(seg, id)
+		if cr == nil {
+			f, sr, err := openSegment(j, seg)
+			if err == errFileGone && !retried {
+				// sealed or trimmed in the meantime
+				j.resetState()
+				continue
+			} else if err != nil {
+				return Record{}, false, err
+			}
+			cr = &cachedReader{file: f, sr: sr}
+		}
+
+		for cr.sr.ID < id {
+			err := cr.sr.next()
+			if err == io.EOF {
+				cr.file.Close() // a draft might have more records later
+				return Record{}, false, nil
+			} else if err != nil {
+				cr.file.Close()
+				return Record{}, false, err
+			}
+		}
+		sr := cr.sr
+		rec := Record{ID: sr.ID, Timestamp: sr.Timestamp, Data: bytes.Clone(sr.Data)}
+		j.gets.put(cr)
+		return rec, rec.ID == id, nil
+	}
+}
+
+// segmentContaining returns the segment that would contain the record with
+// the given ID, or a zero Segment if the ID precedes the first segment.
+func (j *Journal) segmentContaining(id uint64) (Segment, error) {
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	if err := j.state.ensureInitialized(j); err != nil {
+		return Segment{}, err
+	}
+	segs := j.state.readableSegments()
+	i, _ := slices.BinarySearchFunc(segs, id, func(seg Segment, id uint64) int {
+		return cmp.Compare(seg.recnum, id)
+	})
+	if i < len(segs) && segs[i].recnum == id {
+		return segs[i], nil
+	} else if i == 0 {
+		return Segment{}, nil
+	}
+	return segs[i-1], nil
+}
@@ -0,0 +1,59 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalGet(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+	j.StartWriting()
+	must(j.SealAndTrimAll(context.Background()))
+
+	rec := must(j.Get(7))
+	eq(t, rec.ID, 7)
+	eq(t, string(rec.Data), "seven")
+	eq(t, rec.Timestamp, at("20240101T000342000"))
+
+	_, err := j.Get(11)
+	ok(t, errors.Is(err, journal.ErrNotFound))
+	_, err = j.Get(0)
+	ok(t, errors.Is(err, journal.ErrNotFound))
+
+	recs, err := j.GetMany([]uint64{9, 2, 3, 9, 12, 10})
+	ok(t, errors.Is(err, journal.ErrNotFound))
+	var data []string
+	for _, rec := range recs {
+		data = append(data, string(rec.Data))
+	}
+	deepEq(t, data, []string{"nine", "two", "three", "nine", "", "ten"})
+
+	// readers are reused going forward and reopened going back
+	for _, id := range []uint64{3, 4, 4, 3, 8, 7, 8} {
+		eq(t, must(j.Get(id)).ID, id)
+	}
+
+	// trimmed records
+	k := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(k)
+	ensure(os.Remove(filepath.Join(k.Dir, k.FileNames()[0])))
+	k = open(t, k.clock, k.Dir, journal.Options{}, nonVerbose)
+	_, err = k.Get(1)
+	ok(t, errors.Is(err, journal.ErrNotFound))
+	recs = must(k.GetMany([]uint64{4, 3}))
+	eq(t, string(recs[0].Data), "four")
+	eq(t, string(recs[1].Data), "three")
+
+	// records found before an error are returned along with it
+	ensure(os.WriteFile(filepath.Join(k.Dir, k.FileNames()[len(k.FileNames())-1]), []byte("garbage"), 0o644))
+	recs, err = k.GetMany([]uint64{9, 4})
+	ok(t, err != nil && !errors.Is(err, journal.ErrNotFound))
+	eq(t, string(recs[1].Data), "four")
+	eq(t, recs[0].ID, 0)
+}
@@ -5,10 +5,10 @@ go 1.23.3
 require (
 	github.com/andreyvit/sealer v0.2.0
 	github.com/cespare/xxhash/v2 v2.3.0
+	golang.org/x/crypto v0.33.0
 )
 
 require (
 	github.com/klauspost/compress v1.17.11 // indirect
-	golang.org/x/crypto v0.33.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 )
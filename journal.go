@@ -44,8 +44,9 @@
 //
 // Segment files:
 //
-//   - file = segmentHeader item*
-//   - segmentHeader = (see struct)
+//   - file = header extension item*
+//   - header = (see segfile.Header)
+//   - extension = (optional blocks selected by segfile.Header.Features)
 //   - item = record | commit
 //   - record = (size << 1):uvarint timestampDelta:uvarint bytes*
 //   - commit = checksum_with_bit_0_set:64
@@ -62,6 +63,7 @@ package journal
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"log/slog"
 	"os"
@@ -70,13 +72,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/andreyvit/journal/segfile"
 	"github.com/andreyvit/sealer"
 )
 
 var (
 	ErrIncompatible       = fmt.Errorf("incompatible journal")
-	ErrUnsupportedVersion = fmt.Errorf("unsupported journal version")
-	errCorruptedFile      = fmt.Errorf("corrupted journal segment file")
+	ErrUnsupportedVersion = segfile.ErrUnsupportedVersion
+	errCorruptedFile      = segfile.ErrCorrupted
 	errFileGone           = fmt.Errorf("journal segment is gone")
 )
 
@@ -98,8 +101,41 @@ type Options struct {
 
 	OnChange func()
 
+	// AcceptSegmentInvariant, if set, is consulted when a segment header
+	// records a segment invariant other than SegmentInvariant. Returning true
+	// allows reading segments written under an older invariant.
+	AcceptSegmentInvariant func(old [32]byte) bool
+
+	// HashChain makes new segments record the SHA-256 hash chain value of
+	// the previous segment in their headers, and chain the records within
+	// each segment, so that VerifyChain can detect removed, reordered or
+	// rewritten segments.
+	HashChain bool
+
+	// SigningKey, if set, signs the headers and contents of segments when
+	// they are finalized. VerifyKeys, if set, makes reads reject finalized
+	// and sealed segments that aren't signed by one of the given keys.
+	SigningKey ed25519.PrivateKey
+	VerifyKeys []ed25519.PublicKey
+
 	SealKeys []*sealer.Key
 	SealOpts sealer.SealOptions
+
+	// PerSegmentKeys makes Seal encrypt each segment with its own random
+	// data key, wrapped by the seal key and kept in a key store file next to
+	// the segments. This enables Shred.
+	PerSegmentKeys bool
+
+	// EncryptRecords makes new draft segments encrypt each record as it is
+	// written, using a per-segment key derived from the first of SealKeys,
+	// so that data doesn't sit on disk in plaintext until sealed. Adds 40
+	// bytes of overhead per record.
+	EncryptRecords bool
+
+	// RetainUnacknowledged makes Trim keep the unsealed copies of segments
+	// holding records that a registered consumer hasn't acknowledged yet
+	// (see Consumer).
+	RetainUnacknowledged bool
 }
 
 type AutorotateOptions struct {
@@ -127,16 +163,26 @@ type Journal struct {
 	veryVerbose      bool
 	journalInvariant [32]byte
 	segmentInvariant [32]byte
+	acceptSegInv     func(old [32]byte) bool
+	hashChain        bool
+	signingKey       ed25519.PrivateKey
+	verifyKeys       []ed25519.PublicKey
 	onChange         func()
 	autorotate       AutorotateOptions
 	autocommit       AutocommitOptions
 	sealKeys         []*sealer.Key
 	sealOpts         sealer.SealOptions
+	perSegmentKeys   bool
+	encryptRecords   bool
+	retainUnacked    bool
 
-	state    journalState
-	writer   journalWriter
-	sealLock sync.Mutex
-	trimLock sync.Mutex
+	state     journalState
+	writer    journalWriter
+	keys      keyStore
+	consumers consumerStore
+	gets      getCache
+	sealLock  sync.Mutex
+	trimLock  sync.Mutex
 }
 
 func New(dir string, o Options) *Journal {
@@ -178,12 +224,19 @@ func New(dir string, o Options) *Journal {
 		veryVerbose:      o.VeryVerbose,
 		journalInvariant: o.JournalInvariant,
 		segmentInvariant: o.SegmentInvariant,
+		acceptSegInv:     o.AcceptSegmentInvariant,
+		hashChain:        o.HashChain,
+		signingKey:       o.SigningKey,
+		verifyKeys:       o.VerifyKeys,
 		logger:           o.Logger,
 		onChange:         o.OnChange,
 		autorotate:       o.Autorotate,
 		autocommit:       o.Autocommit,
 		sealKeys:         o.SealKeys,
 		sealOpts:         o.SealOpts,
+		perSegmentKeys:   o.PerSegmentKeys,
+		encryptRecords:   o.EncryptRecords,
+		retainUnacked:    o.RetainUnacknowledged,
 	}
 	j.writer.j = j
 	return j
@@ -211,6 +264,16 @@ func (j *Journal) Initialize() error {
 // last committed record. If necessary, it opens, verifies and repairs the
 // journal in the process.
 func (j *Journal) Summary() (Summary, error) {
+	s, err := j.summary()
+	if err != nil {
+		return s, err
+	}
+	s.Gaps, err = j.gaps()
+	return s, err
+}
+
+// summary is Summary without Gaps.
+func (j *Journal) summary() (Summary, error) {
 	s, ok, err := j.immediateSummary()
 	if ok || err != nil {
 		return s, err
@@ -294,10 +357,23 @@ func (j *Journal) Commit() error {
 	return j.writer.Commit()
 }
 
+func (j *Journal) isSegmentInvariantAccepted(inv [32]byte) bool {
+	if inv == j.segmentInvariant {
+		return true
+	}
+	return j.acceptSegInv != nil && j.acceptSegInv(inv)
+}
+
 func (j *Journal) filePath(name string) string {
 	return filepath.Join(j.dir, name)
 }
 
+// metaFilePath returns the path of an auxiliary journal file. These are
+// hidden files, which are skipped when enumerating segments.
+func (j *Journal) metaFilePath(name string) string {
+	return filepath.Join(j.dir, "."+j.fileNamePrefix+name+j.fileNameSuffix)
+}
+
 func (j *Journal) deleteSegment(seg Segment) error {
 	if j.verbose {
 		j.logger.Debug("journal deleting segment", "journal", j.debugName, "seg", seg)
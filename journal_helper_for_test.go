@@ -33,6 +33,8 @@ type nonVerboseOpt struct{}
 
 var nonVerbose = nonVerboseOpt{}
 
+type sealKeys []*sealer.Key
+
 func setupWritable(t testing.TB, clock *fakeClock, o journal.Options, opts ...any) *testJournal {
 	dir := t.TempDir()
 	return open(t, clock, dir, o, opts...)
@@ -56,9 +58,11 @@ func open(t testing.TB, clock *fakeClock, dir string, o journal.Options, opts ..
 	o.Verbose = true
 
 	for _, opt := range opts {
-		switch opt.(type) {
+		switch opt := opt.(type) {
 		case nonVerboseOpt:
 			o.Verbose = false
+		case sealKeys:
+			o.SealKeys = opt
 		default:
 			panic(fmt.Sprintf("unsupported option type: %T", opt))
 		}
@@ -134,3 +138,17 @@ func (j *testJournal) Segments() []string {
 	slices.Sort(names)
 	return names
 }
+
+// collect reads the remaining records of the cursor as ID:data strings.
+func collect(t testing.TB, c *journal.Cursor) []string {
+	t.Helper()
+	defer c.Close()
+	var result []string
+	for c.Next() {
+		result = append(result, fmt.Sprintf("%d:%s", c.ID, c.Data))
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("journal error: %v", err)
+	}
+	return result
+}
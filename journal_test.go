@@ -1,12 +1,18 @@
 package journal_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/andreyvit/journal"
+	"github.com/andreyvit/journal/segfile"
 )
 
 const draft = "'JOURNLAD"
@@ -326,3 +332,100 @@ func TestJournalFilter_latest(t *testing.T) {
 	eqstr(t, recs[3].Data, []byte("98"))
 	eqstr(t, recs[4].Data, []byte("99"))
 }
+
+func TestJournalRead_committedOnly(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.WriteRecord(0, []byte("two")))
+	ensure(j.Commit())
+	ensure(j.WriteRecord(0, []byte("three")))
+
+	// the writer has not committed "three" yet
+	recsEq(t, j.All(journal.Filter{}), 1,
+		"20240101T000000000:one",
+		"20240101T000000000:two")
+
+	// half-written tail
+	name := "jW0000000001-20240101T000000000-000000000001.wal"
+	f := must(os.OpenFile(filepath.Join(j.Dir, name), os.O_WRONLY|os.O_APPEND, 0))
+	must(f.Write(expand("#100 #0 'garbage")))
+	ensure(f.Close())
+	recsEq(t, j.All(journal.Filter{}), 1,
+		"20240101T000000000:one",
+		"20240101T000000000:two")
+}
+
+func TestJournalRead_committedCorruption(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.WriteRecord(0, []byte("two")))
+	ensure(j.Commit())
+	ensure(j.WriteRecord(0, []byte("three")))
+	ensure(j.Commit())
+
+	name := "jW0000000001-20240101T000000000-000000000001.wal"
+	path := filepath.Join(j.Dir, name)
+	b := must(os.ReadFile(path))
+	b[bytes.Index(b, []byte("two"))] = 'T'
+	ensure(os.WriteFile(path, b, 0o644))
+
+	readErr := func(j *journal.Journal) error {
+		var err error
+		for range j.Records(journal.Filter{}, func(e error) { err = e }) {
+		}
+		return err
+	}
+	ok(t, errors.Is(readErr(j.Journal), segfile.ErrCorrupted))
+	k := open(t, j.clock, j.Dir, journal.Options{})
+	ok(t, errors.Is(readErr(k.Journal), segfile.ErrCorrupted))
+}
+
+func TestJournalSegmentInvariant(t *testing.T) {
+	clock := newClock()
+	oldInv := [32]byte{'o', 'l', 'd'}
+	newInv := [32]byte{'n', 'e', 'w'}
+	j1 := setupWritable(t, clock, journal.Options{MaxFileSize: 165, SegmentInvariant: oldInv})
+	ensure(j1.WriteRecord(0, []byte("hello")))
+	ensure(j1.Rotate())
+	clock.Advance(time.Second)
+	ensure(j1.WriteRecord(0, []byte("w")))
+	ensure(j1.FinishWriting())
+
+	j2 := journal.New(j1.Dir, journal.Options{FileName: "j*.wal", SegmentInvariant: newInv, Logger: testLogger(t)})
+	var recs []journal.Record
+	var err error
+	for rec := range j2.Records(journal.Filter{}, func(e error) { err = e }) {
+		recs = append(recs, rec)
+	}
+	eq(t, len(recs), 0)
+	var sie *journal.SegmentInvariantError
+	ok(t, errors.As(err, &sie))
+	ok(t, errors.Is(err, journal.ErrIncompatible))
+	eq(t, sie.Segment.SegmentNumber(), 1)
+	eq(t, sie.Invariant, oldInv)
+
+	j3 := open(t, clock, j1.Dir, journal.Options{
+		MaxFileSize:      165,
+		SegmentInvariant: newInv,
+		AcceptSegmentInvariant: func(old [32]byte) bool {
+			return old == oldInv
+		},
+	})
+	ensure(j3.WriteRecord(0, []byte("x")))
+	ensure(j3.FinishWriting())
+	deepEq(t, j3.FileNames(), []string{
+		"jF0000000001-20240101T000000000-000000000001.wal",
+		"jF0000000002-20240101T000001000-000000000002.wal",
+		"jW0000000003-20240101T000001000-000000000003.wal",
+	})
+	recsEq(t, j3.All(journal.Filter{}), 1,
+		"20240101T000000000:hello",
+		"20240101T000001000:w",
+		"20240101T000001000:x")
+
+	must(j3.SealAndTrimAll(context.Background()))
+	recsEq(t, j3.All(journal.Filter{}), 1,
+		"20240101T000000000:hello",
+		"20240101T000001000:w",
+		"20240101T000001000:x")
+}
@@ -1,6 +1,7 @@
 package journal
 
 import (
+	"cmp"
 	"fmt"
 	"os"
 	"slices"
@@ -16,10 +17,23 @@ type journalState struct {
 	sealed      []Segment
 	sealingTemp Segment
 
+	// lastIDs caches the last record IDs of finalized and sealed segments
+	// by segment number (noLastID if the header cannot be read), and gaps
+	// caches the result of Journal.gaps until the segments change, which
+	// bumps segmentsGen
+	lastIDs     map[uint64]uint64
+	gaps        []Gap
+	gapsKnown   bool
+	segmentsGen uint64
+
 	// set after startWriting, not after .initialize
 	lastKnown     bool
 	lastCommitted Meta
 	lastRaw       Meta
+
+	// commitSignal is closed (and then recreated on demand) when the last
+	// committed record changes; see Journal.Follow.
+	commitSignal chan struct{}
 }
 
 func (j *Journal) needsRotation(now uint64) (bool, error) {
@@ -50,14 +64,63 @@ func (j *Journal) findKnownSegments(filter Filter) ([]Segment, error) {
 	if err := j.state.ensureInitialized(j); err != nil {
 		return nil, err
 	}
-	segs, _ := j.state.findUnsealedSegments(filter)
-	more, _ := j.state.findKnownSealedSegments(filter)
-	if len(more) > 0 {
-		segs = append(more, segs...)
-	}
+	segs, _ := filterSegments(j.state.readableSegments(), filter)
 	return segs, nil
 }
 
+// findReadableSegment returns the readable segment with the given number, or
+// a zero Segment if there is none.
+func (j *Journal) findReadableSegment(segnum uint64) (Segment, error) {
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	if err := j.state.ensureInitialized(j); err != nil {
+		return Segment{}, err
+	}
+	for _, seg := range j.state.readableSegments() {
+		if seg.segnum == segnum {
+			return seg, nil
+		}
+	}
+	return Segment{}, nil
+}
+
+func (j *Journal) findAllSealedSegments(filter Filter) ([]Segment, error) {
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	if err := j.state.ensureInitialized(j); err != nil {
+		return nil, err
+	}
+	segs, _ := filterSegments(j.state.sealed, filter)
+	return slices.Clone(segs), nil
+}
+
+// findOverlappingSealedSegments is a stricter version of findAllSealedSegments
+// that uses the start of the following segment to exclude segments that
+// cannot contain matching records.
+func (j *Journal) findOverlappingSealedSegments(filter Filter) ([]Segment, error) {
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	if err := j.state.ensureInitialized(j); err != nil {
+		return nil, err
+	}
+	var result []Segment
+	for _, seg := range j.state.sealed {
+		if filter.MaxRecordID != 0 && seg.recnum > filter.MaxRecordID {
+			continue
+		}
+		if filter.MaxTimestamp != 0 && seg.ts > filter.MaxTimestamp {
+			continue
+		}
+		if next := j.state.following(seg); next.IsNonZero() {
+			if next.recnum <= filter.MinRecordID || next.ts < filter.MinTimestamp {
+				continue
+			}
+		}
+		result = append(result, seg)
+	}
+	return result, nil
+}
+
 func (j *Journal) nextToSeal() (Segment, error) {
 	j.state.lock.Lock()
 	defer j.state.lock.Unlock()
@@ -76,6 +139,30 @@ func (j *Journal) nextToTrim() (Segment, error) {
 	return j.state.nextToTrim(), nil
 }
 
+// followingSegment returns the known segment that follows seg, or a zero
+// Segment if there is none.
+func (j *Journal) followingSegment(seg Segment) (Segment, error) {
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	if err := j.state.ensureInitialized(j); err != nil {
+		return Segment{}, err
+	}
+	return j.state.following(seg), nil
+}
+
+// isSegmentAcknowledged tells whether all registered consumers have
+// acknowledged all records of the segment.
+func (j *Journal) isSegmentAcknowledged(seg Segment) (bool, error) {
+	acked, ok, err := j.minAcknowledged()
+	if err != nil || !ok {
+		return err == nil, err
+	}
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	next := j.state.following(seg)
+	return next.IsNonZero() && next.recnum-1 <= acked, nil
+}
+
 func (j *Journal) setSealingTemp(seg Segment) {
 	j.state.lock.Lock()
 	defer j.state.lock.Unlock()
@@ -112,12 +199,31 @@ func (j *Journal) setLastRecord(lastCommitted, lastRaw Meta) {
 	j.state.setLastRecord(lastCommitted, lastRaw)
 }
 
+// lastCommittedID returns the ID of the last committed record, if known
+// from the writer.
+func (j *Journal) lastCommittedID() (uint64, bool) {
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	return j.state.lastCommitted.ID, j.state.lastKnown
+}
+
 func (j *Journal) setLastUncommittedRecord(lastRaw Meta) {
 	j.state.lock.Lock()
 	defer j.state.lock.Unlock()
 	j.state.setLastUncommittedRecord(lastRaw)
 }
 
+// commitSignal returns a channel that is closed on the next change of the
+// last committed record.
+func (j *Journal) commitSignal() <-chan struct{} {
+	j.state.lock.Lock()
+	defer j.state.lock.Unlock()
+	if j.state.commitSignal == nil {
+		j.state.commitSignal = make(chan struct{})
+	}
+	return j.state.commitSignal
+}
+
 func (j *Journal) setLastRecordUnknown() {
 	j.state.lock.Lock()
 	defer j.state.lock.Unlock()
@@ -125,6 +231,7 @@ func (j *Journal) setLastRecordUnknown() {
 }
 
 func (js *journalState) reset() {
+	js.segmentsChanged()
 	js.initialized = false
 	js.err = nil
 	js.unsealed = nil
@@ -215,6 +322,20 @@ func (js *journalState) lastSealed() Segment {
 	}
 }
 
+// following returns the known segment with the smallest number after seg.
+func (js *journalState) following(seg Segment) Segment {
+	var next Segment
+	for _, list := range [][]Segment{js.sealed, js.unsealed} {
+		i, _ := slices.BinarySearchFunc(list, seg.segnum+1, func(s Segment, segnum uint64) int {
+			return cmp.Compare(s.segnum, segnum)
+		})
+		if i < len(list) && (next.IsZero() || list[i].segnum < next.segnum) {
+			next = list[i]
+		}
+	}
+	return next
+}
+
 func (js *journalState) nextToSeal() Segment {
 	last := js.lastSealed()
 	for _, seg := range js.unsealed {
@@ -260,6 +381,9 @@ func (js *journalState) summary() Summary {
 }
 
 func (js *journalState) setLastRecord(lastCommitted, lastRaw Meta) {
+	if !js.lastKnown || lastCommitted != js.lastCommitted {
+		js.signalCommit()
+	}
 	js.lastKnown = true
 	js.lastCommitted = lastCommitted
 	js.lastRaw = lastRaw
@@ -267,6 +391,14 @@ func (js *journalState) setLastRecord(lastCommitted, lastRaw Meta) {
 
 func (js *journalState) setLastRecordUnknown() {
 	js.lastKnown = false
+	js.signalCommit()
+}
+
+func (js *journalState) signalCommit() {
+	if js.commitSignal != nil {
+		close(js.commitSignal)
+		js.commitSignal = nil
+	}
 }
 
 func (js *journalState) setLastUncommittedRecord(lastRaw Meta) {
@@ -287,6 +419,7 @@ func (js *journalState) addSegment(j *Journal, seg Segment) {
 	if !js.initialized {
 		return
 	}
+	js.segmentsChanged()
 	if seg.status.IsSealed() {
 		if n := len(js.sealed); n > 0 {
 			prev := js.sealed[n-1]
@@ -310,6 +443,7 @@ func (js *journalState) removeSegment(seg Segment) {
 	if !js.initialized {
 		return
 	}
+	js.segmentsChanged()
 	if seg.status.IsSealed() {
 		if i := slices.Index(js.sealed, seg); i >= 0 {
 			js.sealed = slices.Delete(js.sealed, i, i+1)
@@ -325,64 +459,46 @@ func (js *journalState) replaceSegment(oldSeg, newSeg Segment) {
 	if !js.initialized {
 		return
 	}
+	js.segmentsChanged()
 	if i := slices.Index(js.unsealed, oldSeg); i >= 0 {
 		js.unsealed[i] = newSeg
 	}
 }
 
-func (js *journalState) findKnownSealedSegments(filter Filter) ([]Segment, bool) {
-	sealed := js.sealedSegmentsBeforeUnsealed()
-
-	end := len(sealed)
-	for end > 0 {
-		last := sealed[end-1]
-		if (filter.MaxRecordID > 0 && last.recnum > filter.MaxRecordID) || (filter.MaxTimestamp > 0 && last.ts > filter.MaxTimestamp) {
-			end--
-			continue
-		}
-		break
-	}
-
-	start := 0
-	exactMatch := false
-	for start < end {
-		first := sealed[start]
-		if first.recnum == filter.MinRecordID && first.ts == filter.MinTimestamp {
-			exactMatch = true
-			break
-		} else if first.recnum >= filter.MinRecordID && first.ts >= filter.MinTimestamp {
-			break
-		}
-		start++
-	}
-	if exactMatch {
-		return sealed[start:end], false
-	} else if start > 0 {
-		return sealed[start-1 : end], false
-	} else {
-		return sealed[0:end], true
-	}
+func (js *journalState) segmentsChanged() {
+	js.segmentsGen++
+	js.gapsKnown = false
+	js.gaps = nil
 }
 
-func (js *journalState) sealedSegmentsBeforeUnsealed() []Segment {
-	sealed := js.sealed
-	if len(js.unsealed) == 0 {
-		return sealed
-	}
-
-	first := js.unsealed[0]
-	for len(sealed) > 0 && sealed[len(sealed)-1].segnum >= first.segnum {
-		sealed = sealed[:len(sealed)-1]
+// readableSegments returns a new slice of all known segments ordered by
+// segment number, preferring the unsealed copy of segments that exist in
+// both forms.
+func (js *journalState) readableSegments() []Segment {
+	segs := make([]Segment, 0, len(js.sealed)+len(js.unsealed))
+	sealed, unsealed := js.sealed, js.unsealed
+	for len(sealed) > 0 || len(unsealed) > 0 {
+		if len(unsealed) == 0 || (len(sealed) > 0 && sealed[0].segnum < unsealed[0].segnum) {
+			segs = append(segs, sealed[0])
+			sealed = sealed[1:]
+		} else {
+			if len(sealed) > 0 && sealed[0].segnum == unsealed[0].segnum {
+				sealed = sealed[1:]
+			}
+			segs = append(segs, unsealed[0])
+			unsealed = unsealed[1:]
+		}
 	}
-	return sealed
+	return segs
 }
 
-func (js *journalState) findUnsealedSegments(filter Filter) ([]Segment, bool) {
-	unsealed := js.unsealed
-
-	end := len(unsealed)
+// filterSegments returns the range of segs that can contain records matching
+// the filter. The second value is true if the range starts at the very first
+// segment without an exact match of the filter's lower bounds.
+func filterSegments(segs []Segment, filter Filter) ([]Segment, bool) {
+	end := len(segs)
 	for end > 0 {
-		last := unsealed[end-1]
+		last := segs[end-1]
 		if (filter.MaxRecordID > 0 && last.recnum > filter.MaxRecordID) || (filter.MaxTimestamp > 0 && last.ts > filter.MaxTimestamp) {
 			end--
 			continue
@@ -393,7 +509,7 @@ func (js *journalState) findUnsealedSegments(filter Filter) ([]Segment, bool) {
 	start := 0
 	exactMatch := false
 	for start < end {
-		first := unsealed[start]
+		first := segs[start]
 		if first.recnum == filter.MinRecordID && first.ts == filter.MinTimestamp {
 			exactMatch = true
 			break
@@ -403,10 +519,10 @@ func (js *journalState) findUnsealedSegments(filter Filter) ([]Segment, bool) {
 		start++
 	}
 	if exactMatch {
-		return unsealed[start:end], false
+		return segs[start:end], false
 	} else if start > 0 {
-		return unsealed[start-1 : end], false
+		return segs[start-1 : end], false
 	} else {
-		return unsealed[0:end], true
+		return segs[0:end], true
 	}
 }
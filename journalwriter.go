@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/andreyvit/journal/segfile"
 )
 
 type closeMode int
@@ -32,6 +34,7 @@ type journalWriter struct {
 	segWriter  *segmentWriter
 	nextSegNum uint64
 	nextRecNum uint64
+	nextChain  [segfile.ChainHashSize]byte
 }
 
 func (jw *journalWriter) StartWriting() {
@@ -98,6 +101,7 @@ func (jw *journalWriter) prepareToWrite_locked_once(failed *Segment) error {
 	if last.IsZero() {
 		jw.nextSegNum = 1
 		jw.nextRecNum = 1
+		jw.nextChain = [segfile.ChainHashSize]byte{}
 		lastRec := Meta{ID: 0, Timestamp: 0}
 		jw.j.setLastRecord(lastRec, lastRec)
 		return nil
@@ -115,9 +119,25 @@ func (jw *journalWriter) prepareToWrite_locked_once(failed *Segment) error {
 		jw.segWriter = sw
 		lastRec := sw.lastMeta()
 		jw.j.setLastRecord(lastRec, lastRec)
+
+		// Don't append new records under an older accepted segment invariant,
+		// or signed or encrypted differently than the options ask for.
+		if sw.invariant != jw.j.segmentInvariant {
+			if jw.j.verbose {
+				jw.j.logger.Debug("journal finalizing segment with old invariant", "journal", jw.j.debugName, "seg", last)
+			}
+			return jw.close_locked(closeAndFinalize)
+		}
+		if sw.features&optionFeatures != jw.j.optionFeatures() {
+			if jw.j.verbose {
+				jw.j.logger.Debug("journal finalizing segment with old features", "journal", jw.j.debugName, "seg", last, "features", sw.features)
+			}
+			return jw.close_locked(closeAndFinalize)
+		}
 	} else {
-		var h segmentHeader
-		err := loadSegmentHeader(jw.j, &h, last)
+		var h segfile.Header
+		var ext segfile.Extension
+		err := loadSegmentHeader(jw.j, &h, &ext, last)
 		if err != nil {
 			*failed = last
 			return err
@@ -128,6 +148,7 @@ func (jw *journalWriter) prepareToWrite_locked_once(failed *Segment) error {
 
 		jw.nextSegNum = last.segnum + 1
 		jw.nextRecNum = h.LastRecordNumber + 1
+		jw.nextChain = ext.FinalChainHash
 
 		lastRec := Meta{ID: h.LastRecordNumber, Timestamp: h.LastTimestamp}
 		jw.j.setLastRecord(lastRec, lastRec)
@@ -199,7 +220,7 @@ func (jw *journalWriter) WriteRecord(timestamp uint64, data []byte) error {
 		if jw.j.verbose {
 			jw.j.logger.Debug("journal starting segment", "journal", jw.j.debugName, "segment", segnum, "record", recnum)
 		}
-		sw, err := startSegment(jw.j, segnum, timestamp, recnum)
+		sw, err := startSegment(jw.j, segnum, timestamp, recnum, jw.nextChain)
 		if err != nil {
 			return jw.fail_locked(err)
 		}
@@ -267,6 +288,7 @@ func (jw *journalWriter) close_locked(mode closeMode) error {
 	if mode.shouldFinalize() {
 		jw.nextSegNum = jw.segWriter.seg.segnum + 1
 		jw.nextRecNum = jw.segWriter.nextRec
+		jw.nextChain = jw.segWriter.finalChain()
 	}
 
 	err := jw.segWriter.close(mode)
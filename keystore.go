@@ -0,0 +1,287 @@
+package journal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/andreyvit/sealer"
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	ErrShredded       = errors.New("journal segment has been shredded")
+	ErrNotShreddable  = errors.New("journal segment was not sealed with a per-segment key")
+	errCorruptedStore = errors.New("corrupted journal key store")
+)
+
+// Key store file format:
+//
+//   - file = magic:64 dataKeyEntry* checksum:64
+//   - dataKeyEntry = segnum:64 flags:64 keyID:256 wrapKeyID:256 wrapped:576
+//
+// The wrapped key is XChaCha20-Poly1305 encryption of the data key under
+// the master seal key identified by wrapKeyID, laid out as nonce || sealed,
+// with keyID || segnum as additional data. Shredded entries keep their key
+// ID and segment number, but have the wrapped key zeroed out.
+
+const magicV1KeyStore = uint64('J')<<0 | uint64('O')<<8 | uint64('U')<<16 | uint64('R')<<24 | uint64('N')<<32 | uint64('L')<<40 | uint64('A')<<48 | uint64('K')<<56
+
+const (
+	wrappedKeySize    = chacha20poly1305.NonceSizeX + sealer.KeySize + chacha20poly1305.Overhead
+	dataKeyEntrySize  = 8 + 8 + sealer.IDSize + sealer.IDSize + wrappedKeySize
+	dataKeyShredded   = 1
+	keyStoreFileName  = "keys"
+	keyStoreTempName  = "keys-temp"
+	dataKeyIDTemplate = "journal-data-key:"
+)
+
+type dataKeyEntry struct {
+	segnum    uint64
+	flags     uint64
+	keyID     [sealer.IDSize]byte
+	wrapKeyID [sealer.IDSize]byte
+	wrapped   [wrappedKeySize]byte
+}
+
+func (e *dataKeyEntry) isShredded() bool { return e.flags&dataKeyShredded != 0 }
+
+type keyStore struct {
+	lock    sync.Mutex
+	loaded  bool
+	entries []dataKeyEntry
+}
+
+func (j *Journal) keyStorePath() string {
+	return j.metaFilePath(keyStoreFileName)
+}
+
+func (j *Journal) randomReader() io.Reader {
+	if j.sealOpts.RandomReader != nil {
+		return j.sealOpts.RandomReader
+	}
+	return rand.Reader
+}
+
+// newDataKey generates a random data key for the given segment, wraps it with
+// the master key and durably records it in the key store.
+func (j *Journal) newDataKey(segnum uint64, master *sealer.Key) (*sealer.Key, error) {
+	key := &sealer.Key{}
+	copy(key.ID[:], dataKeyIDTemplate)
+	n := len(dataKeyIDTemplate)
+	if _, err := io.ReadFull(j.randomReader(), key.ID[n:]); err != nil {
+		return nil, fmt.Errorf("generating data key ID: %w", err)
+	}
+	if _, err := io.ReadFull(j.randomReader(), key.Key[:]); err != nil {
+		return nil, fmt.Errorf("generating data key: %w", err)
+	}
+
+	e := dataKeyEntry{segnum: segnum, keyID: key.ID}
+	if err := j.wrapDataKey(&e, key, master); err != nil {
+		return nil, err
+	}
+
+	ks := &j.keys
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if err := j.loadKeyStore_locked(); err != nil {
+		return nil, err
+	}
+	entries := append(slices.Clone(ks.entries), e)
+	if err := j.saveKeyStore_locked(entries); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// dataKey returns the data key with the given ID, nil if the key store has
+// no such key, or ErrShredded if the key has been shredded.
+func (j *Journal) dataKey(keyID [sealer.IDSize]byte) (*sealer.Key, error) {
+	if !isDataKeyID(keyID) {
+		return nil, nil
+	}
+	ks := &j.keys
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if err := j.loadKeyStore_locked(); err != nil {
+		return nil, err
+	}
+	i := ks.find(keyID)
+	if i < 0 {
+		return nil, nil
+	}
+	e := &ks.entries[i]
+	if e.isShredded() {
+		return nil, ErrShredded
+	}
+	master := j.findKey(e.wrapKeyID)
+	if master == nil {
+		return nil, ErrMissingSealKey
+	}
+	return unwrapDataKey(e, master)
+}
+
+// rewrapDataKey re-encrypts the given data key under the master key, unless
+// it is already wrapped with it. Returns whether the key store was changed.
+func (j *Journal) rewrapDataKey(keyID [sealer.IDSize]byte, master *sealer.Key) (bool, error) {
+	ks := &j.keys
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if err := j.loadKeyStore_locked(); err != nil {
+		return false, err
+	}
+	i := ks.find(keyID)
+	if i < 0 {
+		return false, ErrMissingSealKey
+	}
+	e := ks.entries[i]
+	if e.isShredded() {
+		return false, ErrShredded
+	}
+	if e.wrapKeyID == master.ID {
+		return false, nil
+	}
+	old := j.findKey(e.wrapKeyID)
+	if old == nil {
+		return false, ErrMissingSealKey
+	}
+	key, err := unwrapDataKey(&e, old)
+	if err != nil {
+		return false, err
+	}
+	if err := j.wrapDataKey(&e, key, master); err != nil {
+		return false, err
+	}
+	entries := slices.Clone(ks.entries)
+	entries[i] = e
+	return true, j.saveKeyStore_locked(entries)
+}
+
+// shredDataKeys destroys all data keys of the given segments. Returns
+// ErrNotShreddable if any of the segments has no data key.
+func (j *Journal) shredDataKeys(segnums []uint64) error {
+	ks := &j.keys
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if err := j.loadKeyStore_locked(); err != nil {
+		return err
+	}
+	entries := slices.Clone(ks.entries)
+	for _, segnum := range segnums {
+		var found bool
+		for i := range entries {
+			e := &entries[i]
+			if e.segnum == segnum {
+				found = true
+				e.flags |= dataKeyShredded
+				e.wrapped = [wrappedKeySize]byte{}
+			}
+		}
+		if !found {
+			return fmt.Errorf("journal segment %d: %w", segnum, ErrNotShreddable)
+		}
+	}
+	return j.saveKeyStore_locked(entries)
+}
+
+func (ks *keyStore) find(keyID [sealer.IDSize]byte) int {
+	return slices.IndexFunc(ks.entries, func(e dataKeyEntry) bool {
+		return e.keyID == keyID
+	})
+}
+
+func isDataKeyID(keyID [sealer.IDSize]byte) bool {
+	return bytes.HasPrefix(keyID[:], []byte(dataKeyIDTemplate))
+}
+
+func (j *Journal) wrapDataKey(e *dataKeyEntry, key, master *sealer.Key) error {
+	aead, err := chacha20poly1305.NewX(master.Key[:])
+	if err != nil {
+		panic(err)
+	}
+	nonce := e.wrapped[:chacha20poly1305.NonceSizeX]
+	if _, err := io.ReadFull(j.randomReader(), nonce); err != nil {
+		return fmt.Errorf("generating data key nonce: %w", err)
+	}
+	aead.Seal(nonce, nonce, key.Key[:], dataKeyAD(e))
+	e.wrapKeyID = master.ID
+	return nil
+}
+
+func unwrapDataKey(e *dataKeyEntry, master *sealer.Key) (*sealer.Key, error) {
+	aead, err := chacha20poly1305.NewX(master.Key[:])
+	if err != nil {
+		panic(err)
+	}
+	key := &sealer.Key{ID: e.keyID}
+	nonce := e.wrapped[:chacha20poly1305.NonceSizeX]
+	_, err = aead.Open(key.Key[:0], nonce, e.wrapped[chacha20poly1305.NonceSizeX:], dataKeyAD(e))
+	if err != nil {
+		return nil, fmt.Errorf("journal data key of segment %d: %w", e.segnum, err)
+	}
+	return key, nil
+}
+
+func dataKeyAD(e *dataKeyEntry) []byte {
+	return binary.LittleEndian.AppendUint64(e.keyID[:len(e.keyID):len(e.keyID)], e.segnum)
+}
+
+func (j *Journal) loadKeyStore_locked() error {
+	ks := &j.keys
+	if ks.loaded {
+		return nil
+	}
+	b, err := os.ReadFile(j.keyStorePath())
+	if os.IsNotExist(err) {
+		ks.loaded = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	n := len(b) - 16
+	if n < 0 || n%dataKeyEntrySize != 0 || binary.LittleEndian.Uint64(b) != magicV1KeyStore || binary.LittleEndian.Uint64(b[len(b)-8:]) != xxhash.Sum64(b[:len(b)-8]) {
+		j.logger.Error("journal key store is corrupted", "journal", j.debugName, "path", j.keyStorePath())
+		return errCorruptedStore
+	}
+	entries := make([]dataKeyEntry, 0, n/dataKeyEntrySize)
+	for p := b[8 : len(b)-8]; len(p) > 0; p = p[dataKeyEntrySize:] {
+		var e dataKeyEntry
+		e.segnum = binary.LittleEndian.Uint64(p[0:])
+		e.flags = binary.LittleEndian.Uint64(p[8:])
+		copy(e.keyID[:], p[16:])
+		copy(e.wrapKeyID[:], p[16+sealer.IDSize:])
+		copy(e.wrapped[:], p[16+2*sealer.IDSize:])
+		entries = append(entries, e)
+	}
+	ks.entries = entries
+	ks.loaded = true
+	return nil
+}
+
+func (j *Journal) saveKeyStore_locked(entries []dataKeyEntry) error {
+	b := make([]byte, 0, 16+len(entries)*dataKeyEntrySize)
+	b = binary.LittleEndian.AppendUint64(b, magicV1KeyStore)
+	for _, e := range entries {
+		b = binary.LittleEndian.AppendUint64(b, e.segnum)
+		b = binary.LittleEndian.AppendUint64(b, e.flags)
+		b = append(b, e.keyID[:]...)
+		b = append(b, e.wrapKeyID[:]...)
+		b = append(b, e.wrapped[:]...)
+	}
+	b = binary.LittleEndian.AppendUint64(b, xxhash.Sum64(b))
+
+	err := writeFileAtomically(j.metaFilePath(keyStoreTempName), j.keyStorePath(), b)
+	if err != nil {
+		return err
+	}
+	j.keys.entries = entries
+	return nil
+}
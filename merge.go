@@ -2,6 +2,7 @@ package journal
 
 import (
 	"container/heap"
+	"context"
 	"iter"
 )
 
@@ -60,55 +61,79 @@ func (h *cursorHeap) Pop() any {
 
 // MergedRecords returns an iterator that merges records from multiple journals,
 // sorted by timestamp, then by source (for stability), then by record ID.
+// Read errors are reported to fail.
 func MergedRecords(journals map[uint64]*Journal, filter Filter, fail func(error)) iter.Seq[RecordWithSource] {
 	return func(yield func(RecordWithSource) bool) {
+		for rec, err := range MergedAll(journals, filter) {
+			if err != nil {
+				fail(err)
+				return
+			}
+			if !yield(rec) {
+				return
+			}
+		}
+	}
+}
+
+// MergedAll is the iter.Seq2 version of MergedRecords. A read error is
+// yielded once, as the final element.
+func MergedAll(journals map[uint64]*Journal, filter Filter) iter.Seq2[RecordWithSource, error] {
+	return MergedAllContext(context.Background(), journals, filter)
+}
+
+// MergedAllContext is MergedAll that stops when ctx is done, yielding
+// ctx.Err() as the final element.
+func MergedAllContext(ctx context.Context, journals map[uint64]*Journal, filter Filter) iter.Seq2[RecordWithSource, error] {
+	return func(yield func(RecordWithSource, error) bool) {
 		h := make(cursorHeap, 0, len(journals))
-		heap.Init(&h)
+		defer func() {
+			for _, item := range h {
+				item.cursor.Close()
+			}
+		}()
 
 		for source, j := range journals {
 			c := j.Read(filter)
+			c.readCtx = ctx
 			if c.Next() {
-				item := &cursorItem{
+				heap.Push(&h, &cursorItem{
 					source: source,
 					cursor: c,
-				}
-				heap.Push(&h, item)
+				})
 			} else {
 				c.Close()
 				if err := c.Err(); err != nil {
-					fail(err)
+					yield(RecordWithSource{}, err)
 					return
 				}
 			}
 		}
 
 		for h.Len() > 0 {
-			item := heap.Pop(&h).(*cursorItem)
-
+			if err := ctx.Err(); err != nil {
+				yield(RecordWithSource{}, err)
+				return
+			}
+			item := h[0]
 			rec := RecordWithSource{
 				Record: item.cursor.Record,
 				Source: item.source,
 			}
-
-			continueIterating := yield(rec)
+			if !yield(rec, nil) {
+				return
+			}
 
 			if item.cursor.Next() {
-				heap.Push(&h, item)
+				heap.Fix(&h, 0)
 			} else {
+				heap.Pop(&h)
 				item.cursor.Close()
 				if err := item.cursor.Err(); err != nil {
-					fail(err)
+					yield(RecordWithSource{}, err)
+					return
 				}
 			}
-
-			if !continueIterating {
-				break
-			}
-		}
-
-		for h.Len() > 0 {
-			item := heap.Pop(&h).(*cursorItem)
-			item.cursor.Close()
 		}
 	}
 }
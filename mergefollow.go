@@ -0,0 +1,198 @@
+package journal
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"time"
+)
+
+// errWouldBlock is returned by cursors with nowait instead of waiting for
+// new commits.
+var errWouldBlock = errors.New("journal cursor would block")
+
+type followShard struct {
+	item   *cursorItem // in the heap when item.index >= 0
+	j      *Journal
+	lastTS uint64 // timestamp of the last record read
+	ended  bool
+}
+
+// MergedFollow is the following version of MergedAll: it yields the
+// committed records matching the filter from all journals, and then waits
+// for new commits until ctx is done, yielding ctx.Err() as the final
+// element. A journal drops out of the stream once its records go past
+// MaxRecordID or MaxTimestamp of the filter.
+//
+// Records are yielded in timestamp order (then by source and ID) by holding
+// each record back until no other journal can have an older one. A journal
+// that has caught up with its commits is assumed not to commit any records
+// older than its last record, or older than lateness before its current
+// time; so idle journals hold back the stream for at most lateness. Records
+// committed even later than that are yielded as soon as they are seen.
+//
+// As with Follow, only the commits made through the given Journal objects
+// wake up the stream. The data of a yielded record is only valid until the
+// next iteration unless the filter sets CopyData or Arena.
+func MergedFollow(ctx context.Context, journals map[uint64]*Journal, filter Filter, lateness time.Duration) iter.Seq2[RecordWithSource, error] {
+	return func(yield func(RecordWithSource, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		wake := make(chan struct{}, 1)
+		shards := make(map[uint64]*followShard, len(journals))
+		for source, j := range journals {
+			c := j.Follow(ctx, filter)
+			c.nowait = true
+			shards[source] = &followShard{
+				item: &cursorItem{source: source, cursor: c, index: -1},
+				j:    j,
+			}
+			go notifyCommits(ctx, j, wake)
+		}
+		defer func() {
+			for _, s := range shards {
+				s.item.cursor.Close()
+			}
+		}()
+
+		h := make(cursorHeap, 0, len(shards))
+		poll := func(s *followShard) error {
+			c := s.item.cursor
+			if c.poll() {
+				s.lastTS = c.Timestamp
+				heap.Push(&h, s.item)
+			} else if c.err == io.EOF {
+				s.ended = true
+			} else if c.err != nil {
+				return c.err
+			}
+			return nil
+		}
+		pollAll := func() error {
+			for _, s := range shards {
+				if !s.ended && s.item.index < 0 {
+					if err := poll(s); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+
+		if err := pollAll(); err != nil {
+			yield(RecordWithSource{}, err)
+			return
+		}
+		for {
+			var wait time.Duration
+			for h.Len() > 0 {
+				if err := ctx.Err(); err != nil {
+					yield(RecordWithSource{}, err)
+					return
+				}
+				item := h[0]
+				if d := holdBack(shards, item, lateness); d > 0 {
+					wait = d
+					break
+				}
+				rec := RecordWithSource{
+					Record: item.cursor.Record,
+					Source: item.source,
+				}
+				if !yield(rec, nil) {
+					return
+				}
+				heap.Pop(&h)
+				if err := poll(shards[item.source]); err != nil {
+					yield(RecordWithSource{}, err)
+					return
+				}
+			}
+			if h.Len() == 0 && allEnded(shards) {
+				return
+			}
+
+			var timer *time.Timer
+			var timeout <-chan time.Time
+			if wait > 0 {
+				timer = time.NewTimer(wait)
+				timeout = timer.C
+			}
+			select {
+			case <-wake:
+				if err := pollAll(); err != nil {
+					yield(RecordWithSource{}, err)
+					return
+				}
+			case <-timeout:
+			case <-ctx.Done():
+				yield(RecordWithSource{}, ctx.Err())
+				return
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
+}
+
+// holdBack returns how long the record at the top of the heap has to wait
+// for idle journals, or 0 if it can be yielded now. Journals with records in
+// the heap cannot have older ones.
+func holdBack(shards map[uint64]*followShard, top *cursorItem, lateness time.Duration) time.Duration {
+	ts := top.cursor.Timestamp
+	var wait time.Duration
+	for _, s := range shards {
+		if s.ended || s.item.index >= 0 || s.lastTS >= ts {
+			continue
+		}
+		horizon := ToTimestamp(s.j.now().Add(-lateness))
+		if horizon < ts {
+			wait = max(wait, time.Duration(ts-horizon)*time.Millisecond)
+		}
+	}
+	return wait
+}
+
+func allEnded(shards map[uint64]*followShard) bool {
+	for _, s := range shards {
+		if !s.ended {
+			return false
+		}
+	}
+	return true
+}
+
+// notifyCommits signals wake after each commit made through j until ctx is
+// done.
+func notifyCommits(ctx context.Context, j *Journal, wake chan<- struct{}) {
+	for {
+		signal := j.commitSignal()
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+		select {
+		case <-signal:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll is Next for a cursor with nowait: it returns false, leaving Err nil,
+// if the cursor has caught up with the commits.
+func (c *Cursor) poll() bool {
+	if c.err != nil {
+		return false
+	}
+	err := c.next()
+	if err == errWouldBlock {
+		return false
+	}
+	c.err = err
+	return err == nil
+}
@@ -0,0 +1,103 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestMergedFollow(t *testing.T) {
+	clock := newClock()
+	a := setupWritable(t, clock, journal.Options{}, nonVerbose)
+	b := setupWritable(t, clock, journal.Options{}, nonVerbose)
+
+	ensure(a.WriteRecord(0, []byte("a1")))
+	clock.Advance(1 * time.Second)
+	ensure(b.WriteRecord(0, []byte("b1")))
+	clock.Advance(1 * time.Second)
+	ensure(a.WriteRecord(0, []byte("a2")))
+	ensure(a.Commit())
+	ensure(b.Commit())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	journals := map[uint64]*journal.Journal{1: a.Journal, 2: b.Journal}
+	recs := make(chan string)
+	done := make(chan error)
+	go func() {
+		for rec, err := range journal.MergedFollow(ctx, journals, journal.Filter{}, 50*time.Millisecond) {
+			if err != nil {
+				done <- err
+				return
+			}
+			recs <- fmt.Sprintf("%d:%s", rec.Source, rec.Data)
+		}
+		done <- nil
+	}()
+	expect := func(e string) {
+		t.Helper()
+		select {
+		case a := <-recs:
+			eq(t, a, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", e)
+		}
+	}
+	expectNothing := func() {
+		t.Helper()
+		select {
+		case a := <-recs:
+			t.Fatalf("record yielded too early: %s", a)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	expect("1:a1")
+	expect("2:b1")
+	expectNothing() // b is idle and might still commit an older record
+
+	clock.Advance(1 * time.Second)
+	ensure(b.WriteRecord(0, []byte("b2")))
+	ensure(b.Commit())
+	expect("1:a2")
+	expectNothing()
+
+	clock.Advance(1 * time.Second) // past the lateness window of a
+	expect("2:b2")
+
+	// a record later than the window is still yielded
+	ensure(a.WriteRecord(clock.NowTS()-10_000, []byte("a3")))
+	ensure(a.Commit())
+	expect("1:a3")
+
+	cancel()
+	select {
+	case err := <-done:
+		ok(t, errors.Is(err, context.Canceled))
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for cancellation")
+	}
+}
+
+func TestMergedFollow_end(t *testing.T) {
+	a := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	b := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	ensure(a.WriteRecord(0, []byte("a1")))
+	ensure(a.WriteRecord(0, []byte("a2")))
+	ensure(a.Commit())
+	ensure(b.WriteRecord(0, []byte("b1")))
+	ensure(b.WriteRecord(0, []byte("b2")))
+	ensure(b.Commit())
+
+	journals := map[uint64]*journal.Journal{1: a.Journal, 2: b.Journal}
+	var actual []string
+	for rec, err := range journal.MergedFollow(context.Background(), journals, journal.Filter{MaxRecordID: 2}, time.Minute) {
+		ensure(err)
+		actual = append(actual, fmt.Sprintf("%d:%s", rec.Source, rec.Data))
+	}
+	deepEq(t, actual, []string{"1:a1", "1:a2", "2:b1", "2:b2"}) // same timestamps
+}
@@ -0,0 +1,41 @@
+package journal_test
+
+import (
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalRecords_owned(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	writeSeq(j)
+
+	collect := func(filter journal.Filter) []string {
+		var recs []journal.Record
+		for rec := range j.Records(filter, func(err error) { t.Fatal(err) }) {
+			recs = append(recs, rec)
+		}
+		var result []string
+		for _, rec := range recs {
+			result = append(result, string(rec.Data))
+		}
+		return result
+	}
+	all := []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten"}
+
+	deepEq(t, collect(journal.Filter{CopyData: true}), all)
+
+	arena := journal.NewArena()
+	deepEq(t, collect(journal.Filter{Arena: arena}), all)
+	arena.Release()
+	deepEq(t, collect(journal.Filter{Arena: arena, MinRecordID: 9}), all[8:])
+
+	var reversed []journal.Record
+	c := j.ReadReverse(journal.Filter{CopyData: true})
+	for c.Next() {
+		reversed = append(reversed, c.Record)
+	}
+	ensure(c.Err())
+	eq(t, string(reversed[0].Data), "ten")
+	eq(t, string(reversed[9].Data), "one")
+}
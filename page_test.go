@@ -0,0 +1,51 @@
+package journal_test
+
+import (
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalRead_pagination(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+
+	deepEq(t, collect(t, j.Read(journal.Filter{MinRecordID: 3, Limit: 3})), []string{"3:three", "4:four", "5:five"})
+	deepEq(t, collect(t, j.Read(journal.Filter{MinTimestamp: at("20240101T000022000"), Limit: 2})), []string{"5:five", "6:six"})
+	deepEq(t, collect(t, j.Read(journal.Filter{Limit: 2, Offset: 3})), []string{"4:four", "5:five"})
+	deepEq(t, collect(t, j.Read(journal.Filter{Limit: 3, Offset: 2, Latest: true})), []string{"6:six", "7:seven", "8:eight"})
+	deepEq(t, collect(t, j.Read(journal.Filter{Limit: 3, Latest: true, MaxTimestamp: at("20240101T000342000")})), []string{"5:five", "6:six", "7:seven"})
+	deepEq(t, collect(t, j.Read(journal.Filter{Limit: 3, Offset: 20, Latest: true})), []string(nil))
+	deepEq(t, collect(t, j.ReadReverse(journal.Filter{Limit: 2, Offset: 1})), []string{"9:nine", "8:eight"})
+
+	pages := func(read func(journal.Filter) *journal.Cursor, filter journal.Filter) [][]string {
+		var result [][]string
+		for {
+			c := read(filter)
+			page := collect(t, c)
+			if page != nil {
+				result = append(result, page)
+			}
+			var more bool
+			filter, more = c.NextPage()
+			if !more {
+				return result
+			}
+		}
+	}
+	deepEq(t, pages(j.Read, journal.Filter{Limit: 4}), [][]string{
+		{"1:one", "2:two", "3:three", "4:four"},
+		{"5:five", "6:six", "7:seven", "8:eight"},
+		{"9:nine", "10:ten"},
+	})
+	deepEq(t, pages(j.Read, journal.Filter{Limit: 4, Latest: true, MinRecordID: 2}), [][]string{
+		{"7:seven", "8:eight", "9:nine", "10:ten"},
+		{"3:three", "4:four", "5:five", "6:six"},
+		{"2:two"},
+	})
+	deepEq(t, pages(j.ReadReverse, journal.Filter{Limit: 3, MinTimestamp: at("20240101T000002000")}), [][]string{
+		{"10:ten", "9:nine", "8:eight"},
+		{"7:seven", "6:six", "5:five"},
+		{"4:four", "3:three"},
+	})
+}
@@ -0,0 +1,199 @@
+package journal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andreyvit/journal/segfile"
+	"github.com/cespare/xxhash/v2"
+)
+
+var ErrInvalidPosition = errors.New("journal position does not match the journal")
+
+const positionVersion = 1
+
+const positionFlagSealed = 1
+
+// Position is a serializable cursor position just past a record, see
+// Cursor.Position and Journal.ReadFrom.
+type Position struct {
+	Segment   uint64 // segment number
+	Offset    int64  // offset just past the record (within the decrypted stream for sealed segments)
+	RecordID  uint64
+	Timestamp uint64
+
+	sealed   bool
+	dataHash xxhash.Digest
+	chain    [segfile.ChainHashSize]byte
+}
+
+func (pos Position) IsZero() bool { return pos.Segment == 0 }
+
+func (pos Position) String() string {
+	if pos.IsZero() {
+		return "start"
+	}
+	return fmt.Sprintf("%d@%d:%08x", pos.RecordID, pos.Segment, pos.Offset)
+}
+
+func (pos *Position) resumeState() *segfile.ResumeState {
+	return &segfile.ResumeState{
+		End:       pos.Offset,
+		ID:        pos.RecordID,
+		Timestamp: pos.Timestamp,
+		DataHash:  pos.dataHash,
+		Chain:     pos.chain,
+	}
+}
+
+func (pos Position) MarshalBinary() ([]byte, error) {
+	hash, err := pos.dataHash.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 2+4*binary.MaxVarintLen64+len(pos.chain)+len(hash))
+	var flags byte
+	if pos.sealed {
+		flags |= positionFlagSealed
+	}
+	buf = append(buf, positionVersion, flags)
+	buf = binary.AppendUvarint(buf, pos.Segment)
+	buf = binary.AppendUvarint(buf, uint64(pos.Offset))
+	buf = binary.AppendUvarint(buf, pos.RecordID)
+	buf = binary.AppendUvarint(buf, pos.Timestamp)
+	buf = append(buf, pos.chain[:]...)
+	buf = append(buf, hash...)
+	return buf, nil
+}
+
+func (pos *Position) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || data[0] != positionVersion {
+		return fmt.Errorf("%w: unknown encoding", ErrInvalidPosition)
+	}
+	var p Position
+	p.sealed = data[1]&positionFlagSealed != 0
+	data = data[2:]
+	var vals [4]uint64
+	for i := range vals {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("%w: truncated", ErrInvalidPosition)
+		}
+		vals[i] = v
+		data = data[n:]
+	}
+	p.Segment, p.Offset, p.RecordID, p.Timestamp = vals[0], int64(vals[1]), vals[2], vals[3]
+	if len(data) < len(p.chain) {
+		return fmt.Errorf("%w: truncated", ErrInvalidPosition)
+	}
+	copy(p.chain[:], data)
+	if err := p.dataHash.UnmarshalBinary(data[len(p.chain):]); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPosition, err)
+	}
+	if p.IsZero() || p.Offset < 0 || (!p.sealed && p.Offset < segfile.HeaderSize) {
+		return fmt.Errorf("%w: out of range", ErrInvalidPosition)
+	}
+	*pos = p
+	return nil
+}
+
+// Position returns the position just past the last record returned by Next,
+// or the zero Position before the first record. Reverse cursors always
+// return the zero Position.
+func (c *Cursor) Position() Position {
+	return c.pos
+}
+
+func (sr *segmentReader) position() Position {
+	st := sr.ResumeState()
+	return Position{
+		Segment:   sr.seg.segnum,
+		Offset:    st.End,
+		RecordID:  st.ID,
+		Timestamp: st.Timestamp,
+		sealed:    sr.seg.status.IsSealed(),
+		dataHash:  st.DataHash,
+		chain:     st.Chain,
+	}
+}
+
+// ReadFrom returns a cursor that continues after pos, which must come from
+// Cursor.Position of a cursor over this journal. Records matching the filter
+// are returned as with Read.
+//
+// The position is validated against the segment it points into. If that
+// segment is still unsealed, reading resumes at the saved offset without
+// rescanning the earlier records, and the checksum state is verified at the
+// next commit. If it has been sealed since, the segment is decoded up to the
+// saved record. Next fails with ErrInvalidPosition when the segment is gone
+// or does not match the position.
+func (j *Journal) ReadFrom(pos Position, filter Filter) *Cursor {
+	c := j.Read(filter)
+	if !pos.IsZero() {
+		c.resume = &pos
+	}
+	return c
+}
+
+// resumeAt opens the segment of pos and positions the reader past it.
+func (c *Cursor) resumeAt(pos *Position) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %v: %s", ErrInvalidPosition, pos, reason)
+	}
+
+	seg, err := c.j.findReadableSegment(pos.Segment)
+	if err != nil {
+		return err
+	}
+	if seg.IsZero() {
+		return invalid("segment is gone")
+	}
+	if pos.RecordID < seg.recnum || pos.Timestamp < seg.ts {
+		return invalid("record is not in the segment")
+	}
+
+	if !seg.status.IsSealed() && !pos.sealed {
+		f, sr, err := openSegmentAt(c.j, seg, pos.resumeState())
+		if err == ErrInvalidPosition {
+			return invalid("offset is out of range")
+		} else if err != nil {
+			return err
+		}
+		c.file, c.reader = f, sr
+	} else {
+		// sealed in the meantime, the offset is meaningless now
+		c.file, c.reader, err = c.openSegment(seg)
+		if err != nil {
+			return err
+		}
+		for c.reader.ID < pos.RecordID {
+			err := c.reader.next()
+			if err == io.EOF {
+				c.closeFile()
+				return invalid("record is not in the segment")
+			} else if err != nil {
+				c.closeFile()
+				return err
+			}
+		}
+		if c.reader.ID != pos.RecordID || c.reader.Timestamp != pos.Timestamp || (pos.sealed && c.reader.End != pos.Offset) {
+			c.closeFile()
+			return invalid("record does not match")
+		}
+	}
+	c.pos = *pos
+	c.last = pos.RecordID
+
+	c.filter.MinRecordID = max(c.filter.MinRecordID, pos.RecordID+1)
+	segs, err := c.j.FindSegments(c.filter)
+	if err != nil {
+		return err
+	}
+	for len(segs) > 0 && segs[0].segnum <= seg.segnum {
+		segs = segs[1:]
+	}
+	c.segments = segs
+	return nil
+}
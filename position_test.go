@@ -0,0 +1,84 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalReadFrom(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.WriteRecord(0, []byte("two")))
+	ensure(j.Commit())
+
+	c := j.Read(journal.Filter{})
+	eq(t, c.Position().IsZero(), true)
+	deepEq(t, collect(t, c), []string{"1:one", "2:two"})
+	pos := c.Position()
+	eq(t, pos.RecordID, 2)
+
+	token := must(pos.MarshalBinary())
+	var decoded journal.Position
+	ensure(decoded.UnmarshalBinary(token))
+	eqstr(t, must(decoded.MarshalBinary()), token)
+	deepEq(t, collect(t, j.ReadFrom(decoded, journal.Filter{})), []string(nil))
+
+	ensure(j.WriteRecord(0, []byte("three")))
+	ensure(j.Commit())
+	deepEq(t, collect(t, j.ReadFrom(decoded, journal.Filter{})), []string{"3:three"})
+	deepEq(t, collect(t, j.ReadFrom(journal.Position{}, journal.Filter{})), []string{"1:one", "2:two", "3:three"})
+
+	// the segment gets sealed in between
+	ensure(j.Rotate())
+	ensure(j.WriteRecord(0, []byte("four")))
+	ensure(j.Commit())
+	must(j.SealAndTrimOnce(context.Background()))
+	deepEq(t, collect(t, j.ReadFrom(decoded, journal.Filter{})), []string{"3:three", "4:four"})
+
+	// positions within a sealed segment
+	for _, filter := range []journal.Filter{{MaxRecordID: 3}, {MaxRecordID: 3, Prefetch: 2}} {
+		c = j.Read(filter)
+		deepEq(t, collect(t, c), []string{"1:one", "2:two", "3:three"})
+		deepEq(t, collect(t, j.ReadFrom(c.Position(), journal.Filter{})), []string{"4:four"})
+
+		token := must(c.Position().MarshalBinary())
+		var sealed journal.Position
+		ensure(sealed.UnmarshalBinary(token))
+		deepEq(t, collect(t, j.ReadFrom(sealed, journal.Filter{})), []string{"4:four"})
+	}
+}
+
+func TestJournalReadFrom_invalid(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.Commit())
+	c := j.Read(journal.Filter{})
+	collect(t, c)
+	pos := c.Position()
+
+	// a different journal with an identical layout
+	k := setupWritable(t, newClock(), journal.Options{})
+	ensure(k.WriteRecord(0, []byte("uno")))
+	ensure(k.WriteRecord(0, []byte("dos")))
+	ensure(k.Commit())
+
+	fails := func(c *journal.Cursor) {
+		t.Helper()
+		defer c.Close()
+		for c.Next() {
+		}
+		if err := c.Err(); !errors.Is(err, journal.ErrInvalidPosition) {
+			t.Errorf("Err() = %v, wanted ErrInvalidPosition", err)
+		}
+	}
+	fails(k.ReadFrom(pos, journal.Filter{}))
+	fails(j.ReadFrom(journal.Position{Segment: 9, Offset: 200, RecordID: 1}, journal.Filter{}))
+	fails(j.ReadFrom(journal.Position{Segment: 1, Offset: 1 << 20, RecordID: 1}, journal.Filter{}))
+
+	var decoded journal.Position
+	ok(t, errors.Is(decoded.UnmarshalBinary([]byte{1, 0, 1}), journal.ErrInvalidPosition))
+	ok(t, errors.Is(decoded.UnmarshalBinary(nil), journal.ErrInvalidPosition))
+}
@@ -0,0 +1,163 @@
+package journal
+
+import (
+	"context"
+	"io"
+)
+
+// prefetchState decodes sealed segments ahead of a cursor in background
+// goroutines; see Filter.Prefetch. queue[i] yields the decoded c.segments[i].
+type prefetchState struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  []chan *decodedSegment
+	cur    *decodedSegment
+}
+
+type decodedSegment struct {
+	seg     Segment
+	entries []decodedEntry
+	data    []byte
+	err     error  // stopped decoding
+	next    uint64 // first record ID not decoded
+}
+
+type decodedEntry struct {
+	id         uint64
+	ts         uint64
+	endOffset  int64
+	start, end int
+}
+
+// schedulePrefetch starts decoding the sealed segments at the front of
+// c.segments, keeping up to Filter.Prefetch of them in flight.
+func (c *Cursor) schedulePrefetch() {
+	pf := c.pf
+	if pf == nil {
+		pf = &prefetchState{}
+		pf.ctx, pf.cancel = context.WithCancel(c.j.context)
+		if c.readCtx != nil {
+			stop := context.AfterFunc(c.readCtx, pf.cancel)
+			cancel := pf.cancel
+			pf.cancel = func() {
+				stop()
+				cancel()
+			}
+		}
+		c.pf = pf
+	}
+	for len(pf.queue) < c.filter.Prefetch && len(pf.queue) < len(c.segments) {
+		seg := c.segments[len(pf.queue)]
+		if !seg.status.IsSealed() {
+			break // unsealed segments are read in place, see next
+		}
+		ch := make(chan *decodedSegment, 1)
+		pf.queue = append(pf.queue, ch)
+		go func(filter Filter) {
+			ch <- decodeSegment(pf.ctx, c.openSegment, seg, filter)
+		}(c.filter)
+	}
+}
+
+// nextPrefetched makes the next prefetched segment current, returning false
+// if the next segment isn't being prefetched.
+func (c *Cursor) nextPrefetched() (bool, error) {
+	c.schedulePrefetch()
+	pf := c.pf
+	if len(pf.queue) == 0 {
+		return false, nil
+	}
+	ch := pf.queue[0]
+	pf.queue = pf.queue[1:]
+	c.segments = c.segments[1:]
+
+	pf.cur = <-ch
+	c.schedulePrefetch()
+	if err := c.gapError(pf.cur.seg); err != nil {
+		pf.cur = nil
+		return false, err
+	}
+	return true, nil
+}
+
+// nextDecoded returns the next record of the current prefetched segment,
+// or false once it is exhausted, along with the error that stopped decoding
+// the segment, if any.
+func (c *Cursor) nextDecoded() (bool, error) {
+	ds := c.pf.cur
+	var e *decodedEntry
+	for e == nil || !c.filter.matchesData(e.id, e.ts, ds.data[e.start:e.end:e.end]) {
+		if len(ds.entries) == 0 {
+			c.pf.cur = nil
+			if ds.err == errFileGone {
+				if ok, err := c.refind(ds.seg); err != nil || ok {
+					return false, err
+				}
+			}
+			if ds.err != nil {
+				return false, c.segmentError(ds.seg, ds.next, ds.err)
+			}
+			c.ended = ds.next - 1
+			return false, nil
+		}
+		e = &ds.entries[0]
+		ds.entries = ds.entries[1:]
+		c.last = e.id
+	}
+	c.Record = Record{
+		ID:        e.id,
+		Timestamp: e.ts,
+		Data:      c.filter.ownData(ds.data[e.start:e.end:e.end]),
+	}
+	c.pos = Position{
+		Segment:   ds.seg.segnum,
+		Offset:    e.endOffset,
+		RecordID:  e.id,
+		Timestamp: e.ts,
+		sealed:    true,
+	}
+	return true, nil
+}
+
+func (c *Cursor) stopPrefetch() {
+	if c.pf != nil {
+		c.pf.cancel()
+		c.pf = nil
+	}
+}
+
+// decodeSegment reads the records of the segment within the ID and time
+// ranges of the filter. Content conditions are left to nextDecoded, so that
+// Match is only ever called on the cursor's goroutine.
+func decodeSegment(ctx context.Context, open func(Segment) (io.Closer, *segmentReader, error), seg Segment, filter Filter) *decodedSegment {
+	ds := &decodedSegment{seg: seg, next: seg.recnum}
+	f, sr, err := open(seg)
+	if err != nil {
+		ds.err = err
+		return ds
+	}
+	defer f.Close()
+
+	for i := 0; ; i++ {
+		if i%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				ds.err = err
+				return ds
+			}
+		}
+		err := sr.next()
+		if err == io.EOF {
+			return ds
+		} else if err != nil {
+			ds.err = err
+			return ds
+		}
+		ds.next = sr.ID + 1
+		if !filter.matches(sr.ID, sr.Timestamp) {
+			continue
+		}
+		start := len(ds.data)
+		ds.data = append(ds.data, sr.Data...)
+		ds.entries = append(ds.entries, decodedEntry{sr.ID, sr.Timestamp, sr.End, start, len(ds.data)})
+	}
+}
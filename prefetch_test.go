@@ -0,0 +1,64 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalRead_prefetch(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+	j.StartWriting()
+	ensure(j.WriteRecord(0, []byte("eleven")))
+	ensure(j.Commit())
+	must(j.Seal(context.Background()))
+	must(j.Seal(context.Background()))
+	must(j.Seal(context.Background()))
+
+	all := collect(t, j.Read(journal.Filter{}))
+	eq(t, len(all), 11)
+	for _, n := range []int{1, 2, 10} {
+		deepEq(t, collect(t, j.Read(journal.Filter{Prefetch: n})), all)
+		deepEq(t, collect(t, j.Read(journal.Filter{Prefetch: n, MinRecordID: 4, MaxRecordID: 9})), all[3:9])
+	}
+
+	c := j.Read(journal.Filter{Prefetch: 2})
+	ok(t, c.Next())
+	ok(t, c.Next())
+	eq(t, string(c.Data), "two")
+	pos := c.Position()
+	ensure(c.SeekID(8))
+	deepEq(t, collect(t, c), all[7:])
+	deepEq(t, collect(t, j.ReadFrom(pos, journal.Filter{Prefetch: 2})), all[2:])
+
+	// abandoning a cursor stops decoding
+	c = j.Read(journal.Filter{Prefetch: 3})
+	ok(t, c.Next())
+	c.Close()
+}
+
+func TestJournalAllContext_prefetch(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+	j.StartWriting()
+	must(j.SealAndTrimAll(context.Background()))
+
+	// prefetching is tied to the caller's context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var data []string
+	var err error
+	for rec, e := range j.AllContext(ctx, journal.Filter{Prefetch: 3, CopyData: true}) {
+		if e != nil {
+			err = e
+			break
+		}
+		data = append(data, string(rec.Data))
+		cancel()
+	}
+	deepEq(t, data, []string{"one"})
+	ok(t, errors.Is(err, context.Canceled))
+}
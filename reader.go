@@ -1,11 +1,14 @@
 package journal
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"iter"
-	"os"
 	"time"
+
+	"github.com/andreyvit/journal/segfile"
 )
 
 var ErrInternal = errors.New("journal internal error")
@@ -35,29 +38,88 @@ type Cursor struct {
 	Record
 
 	closed   bool
+	started  bool
 	j        *Journal
 	filter   Filter
 	err      error
 	segments []Segment
-	file     *os.File
+	file     io.Closer
 	reader   *segmentReader
+
+	// set when following; see Follow
+	ctx       context.Context
+	committed uint64
+	last      uint64
+	nowait    bool // see MergedFollow
+
+	readCtx context.Context // set by AllContext and MergedAllContext, stops prefetching
+
+	rev  *reverseState  // set by ReadReverse
+	pf   *prefetchState // see Filter.Prefetch
+	snap *Snapshot      // set by Snapshot.Read
+
+	orig       Filter // as passed by the caller, see NextPage
+	countLimit bool   // Limit is enforced by counting returned records
+	skipped    int    // for Offset
+	returned   int
+	firstID    uint64 // of the returned records
+	lastID     uint64
+
+	skippedRanges []SkippedRange
+	ended         uint64 // last record ID of the previous segment, see Filter.FailOnGap
+	refound       uint64 // segment number that was gone, see refind
+
+	pos    Position
+	resume *Position // set by ReadFrom until the cursor starts
 }
 
 func (j *Journal) Read(filter Filter) *Cursor {
 	return &Cursor{
 		j:      j,
 		filter: filter,
+		orig:   filter,
 		file:   nil,
 		reader: nil,
 	}
 }
 
+// Follow returns a cursor that yields the committed records matching the
+// filter, and then waits for new commits until ctx is done, moving on to new
+// segments as the journal rotates. Next returns false when ctx is done (with
+// Err returning ctx.Err()) or when the records go past MaxRecordID or
+// MaxTimestamp of the filter.
+//
+// Only the commits made through this Journal wake up the cursor.
+func (j *Journal) Follow(ctx context.Context, filter Filter) *Cursor {
+	c := j.Read(filter)
+	c.ctx = ctx
+	return c
+}
+
+// FollowRecords is an iterator version of Follow. Errors, including
+// the cancellation of ctx, are yielded once at the end.
+func (j *Journal) FollowRecords(ctx context.Context, filter Filter) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		c := j.Follow(ctx, filter)
+		defer c.Close()
+		for c.Next() {
+			if !yield(c.Record, nil) {
+				return
+			}
+		}
+		if err := c.Err(); err != nil {
+			yield(Record{}, err)
+		}
+	}
+}
+
 func (c *Cursor) Close() {
 	if c.closed {
 		return
 	}
 	c.closed = true
 	c.closeFile()
+	c.stopPrefetch()
 }
 
 func (c *Cursor) Err() error {
@@ -84,92 +146,462 @@ func (c *Cursor) closeFile() {
 }
 
 func (c *Cursor) next() error {
-	var lim uint64
-	if c.filter.Limit > 0 {
-		lim = uint64(c.filter.Limit)
+	if c.rev != nil {
+		return c.nextReverse()
 	}
 
-	if c.segments == nil {
-		if lim > 0 {
-			sum, err := c.j.Summary()
-			if err != nil {
+	if !c.started {
+		if err := c.start(); err != nil {
+			return err
+		}
+		if pos := c.resume; pos != nil {
+			c.resume = nil
+			if err := c.resumeAt(pos); err != nil {
 				return err
 			}
-			if c.filter.Latest {
-				if sum.LastCommitted.ID >= lim {
-					c.filter.MinRecordID = max(c.filter.MinRecordID, sum.LastCommitted.ID-lim+1)
-				}
-			} else {
-				first := sum.FirstRecord().ID
-				c.filter.MinRecordID = first
-				c.filter.MaxRecordID = first + lim - 1
+		} else {
+			var err error
+			c.segments, err = c.findSegments()
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		var err error
-		c.segments, err = c.j.FindSegments(c.filter)
-		if err != nil {
-			return err
-		}
+	if c.countLimit && c.returned >= c.filter.Limit {
+		return io.EOF
 	}
 
 	for {
+		if c.pf != nil && c.pf.cur != nil {
+			ok, err := c.nextDecoded()
+			if err != nil {
+				return err
+			}
+			if !ok || !c.admit() {
+				continue
+			}
+			return nil
+		}
 		if c.reader == nil {
 			if len(c.segments) == 0 {
-				return io.EOF
+				if c.ctx == nil || c.isPastFilter() {
+					return io.EOF
+				}
+				if c.last >= c.committed {
+					if err := c.waitForCommit(); err != nil {
+						return err
+					}
+				}
+				c.filter.MinRecordID = max(c.filter.MinRecordID, c.last+1)
+				var err error
+				c.segments, err = c.findSegments()
+				if err != nil {
+					return err
+				}
+				if len(c.segments) == 0 {
+					if err := c.waitForCommit(); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if c.filter.Prefetch > 0 && c.ctx == nil {
+				if ok, err := c.nextPrefetched(); err != nil {
+					return err
+				} else if ok {
+					continue
+				}
 			}
 			seg := c.segments[0]
 			c.segments = c.segments[1:]
+			if err := c.gapError(seg); err != nil {
+				return err
+			}
 
 			var err error
-			c.file, c.reader, err = openSegment(c.j, seg)
+			c.file, c.reader, err = c.openSegment(seg)
+			if err == errFileGone && c.ctx != nil {
+				// renamed by finalization, sealing or trimming
+				c.j.resetState()
+				c.segments = nil
+				continue
+			} else if err == errFileGone {
+				if ok, err := c.refind(seg); err != nil {
+					return err
+				} else if ok {
+					continue
+				}
+			}
 			if err != nil {
+				if err := c.segmentError(seg, seg.recnum, err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if c.ctx != nil && c.reader.ID >= c.committed {
+			// never read past the last commit, the rest might still be
+			// in the middle of being written
+			if c.isPastFilter() {
+				return io.EOF
+			}
+			if err := c.waitForCommit(); err != nil {
 				return err
 			}
+			continue
 		}
 
 		err := c.reader.next()
 		if err == io.EOF {
+			c.ended = c.reader.ID
 			c.closeFile()
 			continue
 		} else if err != nil {
+			seg, from := c.reader.seg, c.reader.ID+1
 			c.closeFile()
-			return err
+			if errors.Is(err, segfile.ErrResumeMismatch) {
+				return fmt.Errorf("%w: %v", ErrInvalidPosition, err)
+			}
+			if err := c.segmentError(seg, from, err); err != nil {
+				return err
+			}
+			continue
 		}
 		c.Record = Record{
-			ID:        c.reader.rec,
-			Timestamp: c.reader.ts,
-			Data:      c.reader.data,
+			ID:        c.reader.ID,
+			Timestamp: c.reader.Timestamp,
+			Data:      c.reader.Data,
 		}
+		c.last = c.Record.ID
 		if c.filter.MinRecordID != 0 && c.Record.ID < c.filter.MinRecordID {
 			continue
 		}
 		if c.filter.MaxRecordID != 0 && c.Record.ID > c.filter.MaxRecordID {
+			if c.ctx != nil {
+				return io.EOF
+			}
 			continue
 		}
 		if c.filter.MinTimestamp != 0 && c.Record.Timestamp < c.filter.MinTimestamp {
 			continue
 		}
 		if c.filter.MaxTimestamp != 0 && c.Record.Timestamp > c.filter.MaxTimestamp {
+			if c.ctx != nil {
+				return io.EOF
+			}
 			continue
 		}
+		if !c.filter.matchesData(c.Record.ID, c.Record.Timestamp, c.Record.Data) || !c.admit() {
+			continue
+		}
+		c.Record.Data = c.filter.ownData(c.Record.Data)
+		c.pos = c.reader.position()
+		return nil
+	}
+}
+
+// refind looks for the unread segments again after seg turned out to be
+// gone, e.g. sealed and trimmed while the cursor was running. Returns false
+// if seg is still gone after that, or can never be found again because
+// the cursor reads a snapshot.
+func (c *Cursor) refind(seg Segment) (bool, error) {
+	if c.snap != nil || c.refound == seg.segnum {
+		return false, nil
+	}
+	c.refound = seg.segnum
+	c.j.resetState()
+	c.stopPrefetch()
+	c.filter.MinRecordID = max(c.filter.MinRecordID, c.last+1)
+	var err error
+	c.segments, err = c.findSegments()
+	return true, err
+}
+
+// start applies Limit and Offset and, when following, loads the last commit
+// on the first use of the cursor.
+func (c *Cursor) start() error {
+	c.started = true
+	if c.rev != nil {
+		return nil
+	}
+
+	if c.filter.Latest && c.filter.Limit > 0 {
+		f := &c.filter
+		if f.Offset == 0 && f.MaxRecordID == 0 && f.MinTimestamp == 0 && f.MaxTimestamp == 0 && !f.hasContentFilter() {
+			// every record matches, no need to look for them
+			lim := uint64(f.Limit)
+			last, err := c.lastCommitted()
+			if err != nil {
+				return err
+			}
+			if last >= lim {
+				f.MinRecordID = max(f.MinRecordID, last-lim+1)
+			}
+		} else if err := c.findLatestMatching(); err != nil {
+			return err
+		}
+	}
+	c.countLimit = c.filter.Limit > 0
+
+	if c.ctx != nil {
+		sum, err := c.j.summary()
+		if err != nil {
+			return err
+		}
+		c.committed = sum.LastCommitted.ID
+	}
+	return nil
+}
+
+func (c *Cursor) findSegments() ([]Segment, error) {
+	if c.snap != nil {
+		return c.snap.findSegments(c.filter), nil
+	}
+	return c.j.FindSegments(c.filter)
+}
+
+func (c *Cursor) openSegment(seg Segment) (io.Closer, *segmentReader, error) {
+	if c.snap != nil {
+		return c.snap.openSegment(seg)
+	}
+	f, sr, err := openSegment(c.j, seg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, sr, nil
+}
+
+func (c *Cursor) lastCommitted() (uint64, error) {
+	if c.snap != nil {
+		return c.snap.last.ID, nil
+	}
+	sum, err := c.j.summary()
+	return sum.LastCommitted.ID, err
+}
+
+// admit counts a matching record against Offset and tells whether to return
+// it.
+func (c *Cursor) admit() bool {
+	if c.skipped < c.filter.Offset {
+		c.skipped++
+		return false
+	}
+	c.returned++
+	if c.returned == 1 {
+		c.firstID = c.Record.ID
+	}
+	c.lastID = c.Record.ID
+	return true
+}
+
+// NextPage returns the filter that continues after the records returned so
+// far: with the following records, or, for Latest and ReadReverse, with the
+// preceding ones. It is meant to be called once Next returns false, and
+// composes with the ID and time bounds of the original filter. Returns false
+// if the cursor has run out of matching records before reaching Limit.
+func (c *Cursor) NextPage() (Filter, bool) {
+	f := c.orig
+	if c.returned == 0 {
+		return f, c.err == nil
+	}
+	if c.err == io.EOF && (f.Limit == 0 || c.returned < f.Limit) {
+		return f, false
+	}
+	f.Offset = 0
+	if c.rev != nil {
+		f.MaxRecordID = c.lastID - 1
+	} else if f.Latest {
+		f.MaxRecordID = c.firstID - 1
+	} else {
+		f.MinRecordID = c.lastID + 1
+	}
+	if f.MaxRecordID == 0 && (c.rev != nil || f.Latest) {
+		return f, false // no records before ID 1
+	}
+	return f, true
+}
+
+// findLatestMatching narrows the ID range of the filter to the Limit
+// matching records that precede the newest Offset ones. Returns io.EOF if
+// there are no such records.
+func (c *Cursor) findLatestMatching() error {
+	f := c.filter
+	f.Latest, f.Limit, f.Offset = false, f.Limit+f.Offset, 0
+	f.CopyData, f.Arena, f.Prefetch = false, nil, 0
+	var r *Cursor
+	if c.snap != nil {
+		r = c.snap.ReadReverse(f)
+	} else {
+		r = c.j.ReadReverse(f)
+	}
+	defer r.Close()
+	var n int
+	for r.Next() {
+		if n == c.filter.Offset {
+			c.filter.MaxRecordID = r.ID
+		}
+		if n >= c.filter.Offset {
+			c.filter.MinRecordID = r.ID
+		}
+		n++
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if n <= c.filter.Offset {
+		return io.EOF
+	}
+	c.filter.Offset = 0
+	return nil
+}
+
+// SeekID repositions the cursor so that Next returns the first matching
+// record with an ID of at least id (for reverse cursors, at most id). It
+// replaces the lower (for reverse cursors, upper) bounds of the filter.
+func (c *Cursor) SeekID(id uint64) error {
+	if c.rev != nil {
+		return c.seekReverse(id, 0)
+	}
+	inPlace := c.reader != nil && c.reader.ID < id && (len(c.segments) == 0 || c.segments[0].recnum > id)
+	return c.seek(id, 0, inPlace)
+}
+
+// SeekTime repositions the cursor so that Next returns the first matching
+// record at or after t (for reverse cursors, at or before t). It replaces
+// the lower (for reverse cursors, upper) bounds of the filter.
+func (c *Cursor) SeekTime(t time.Time) error {
+	ts := ToTimestamp(t)
+	if c.rev != nil {
+		return c.seekReverse(0, ts)
+	}
+	inPlace := c.reader != nil && c.reader.Timestamp < ts && (len(c.segments) == 0 || c.segments[0].ts > ts)
+	return c.seek(0, ts, inPlace)
+}
+
+func (c *Cursor) seek(minID, minTS uint64, inPlace bool) error {
+	if c.closed {
+		return nil
+	}
+	if !c.started {
+		if err := c.start(); err != nil {
+			c.err = err
+			return err
+		}
+	}
+	c.filter.MinRecordID = minID
+	c.filter.MinTimestamp = minTS
+	c.err = nil
+	if inPlace {
+		// the target is ahead within the open segment, skip to it
+		return nil
+	}
+
+	c.closeFile()
+	c.stopPrefetch()
+	c.last = 0
+	c.ended = 0
+	if minID > 0 {
+		c.last = minID - 1
+	}
+	var err error
+	c.segments, err = c.findSegments()
+	if err != nil {
+		c.err = err
+	}
+	return err
+}
+
+func (c *Cursor) seekReverse(maxID, maxTS uint64) error {
+	if c.closed {
 		return nil
 	}
+	c.started = true
+	c.filter.MaxRecordID = maxID
+	c.filter.MaxTimestamp = maxTS
+	c.err = nil
+	*c.rev = reverseState{table: c.rev.table[:0], arena: c.rev.arena[:0]}
+	var err error
+	c.segments, err = c.findSegments()
+	if err != nil {
+		c.err = err
+	}
+	return err
+}
+
+// isPastFilter tells whether a following cursor has seen all records the
+// filter can match.
+func (c *Cursor) isPastFilter() bool {
+	return c.filter.MaxRecordID != 0 && c.last >= c.filter.MaxRecordID
+}
+
+// waitForCommit blocks until records past the last known commit get
+// committed.
+func (c *Cursor) waitForCommit() error {
+	for {
+		signal := c.j.commitSignal()
+		sum, err := c.j.summary()
+		if err != nil {
+			return err
+		}
+		if sum.LastCommitted.ID > c.committed {
+			c.committed = sum.LastCommitted.ID
+			return nil
+		}
+		if c.nowait {
+			return errWouldBlock
+		}
+		select {
+		case <-signal:
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
 }
 
+// Records iterates over the records matching the filter, reporting a read
+// error to fail. The data of a yielded record is only valid until the next
+// iteration unless the filter sets CopyData or Arena.
 func (j *Journal) Records(filter Filter, fail func(error)) iter.Seq[Record] {
 	return func(yield func(Record) bool) {
+		for rec, err := range j.All(filter) {
+			if err != nil {
+				fail(err)
+				return
+			}
+			if !yield(rec) {
+				return
+			}
+		}
+	}
+}
+
+// All iterates over the records matching the filter. A read error is
+// yielded once, as the final element. The data of a yielded record is only
+// valid until the next iteration unless the filter sets CopyData or Arena.
+func (j *Journal) All(filter Filter) iter.Seq2[Record, error] {
+	return j.AllContext(context.Background(), filter)
+}
+
+// AllContext is All that stops when ctx is done, yielding ctx.Err() as the
+// final element.
+func (j *Journal) AllContext(ctx context.Context, filter Filter) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
 		c := j.Read(filter)
+		c.readCtx = ctx
 		defer c.Close()
 		for c.Next() {
-			cont := yield(c.Record)
-			if !cont {
-				break
+			if err := ctx.Err(); err != nil {
+				yield(Record{}, err)
+				return
+			}
+			if !yield(c.Record, nil) {
+				return
 			}
 		}
-		err := c.Err()
-		if err != nil {
-			fail(err)
+		if err := c.Err(); err != nil {
+			yield(Record{}, err)
 		}
 	}
 }
@@ -0,0 +1,32 @@
+package journal
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andreyvit/journal/segfile"
+)
+
+var errEncryptionWithoutKeys = errors.New("journal: EncryptRecords requires SealKeys")
+
+func (j *Journal) newRecordAEAD(ext *segfile.Extension) (cipher.AEAD, error) {
+	if len(j.sealKeys) == 0 {
+		return nil, errEncryptionWithoutKeys
+	}
+	key := j.sealKeys[0]
+	ext.RecordKeyID = key.ID
+	if _, err := io.ReadFull(j.randomReader(), ext.RecordKeySalt[:]); err != nil {
+		return nil, fmt.Errorf("generating record key salt: %w", err)
+	}
+	return segfile.RecordAEAD(key, &ext.RecordKeySalt), nil
+}
+
+func (j *Journal) openRecordAEAD(ext *segfile.Extension) (cipher.AEAD, error) {
+	key := j.findKey(ext.RecordKeyID)
+	if key == nil {
+		return nil, ErrMissingSealKey
+	}
+	return segfile.RecordAEAD(key, &ext.RecordKeySalt), nil
+}
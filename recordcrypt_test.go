@@ -0,0 +1,76 @@
+package journal_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalEncryptRecords(t *testing.T) {
+	o := journal.Options{
+		MaxFileSize:    1024,
+		EncryptRecords: true,
+	}
+	j := setupWritable(t, newClock(), o)
+	ensure(j.WriteRecord(0, []byte("secret one")))
+	j.clock.Advance(time.Second)
+	ensure(j.WriteRecord(0, []byte("secret two")))
+	ensure(j.FinishWriting())
+
+	name := "jW0000000001-20240101T000000000-000000000001.wal"
+	deepEq(t, j.FileNames(), []string{name})
+	ok(t, !bytes.Contains(j.Data(name), []byte("secret")))
+	recsEq(t, j.All(journal.Filter{}), 1,
+		"20240101T000000000:secret one",
+		"20240101T000001000:secret two")
+
+	// recovery: uncommitted tail is truncated, and writing continues
+	garbled := append(j.Data(name), expand("#100 #0 'garbage")...)
+	ensure(os.WriteFile(filepath.Join(j.Dir, name), garbled, 0o644))
+	j2 := open(t, j.clock, j.Dir, o)
+	ensure(j2.WriteRecord(0, []byte("secret three")))
+	ensure(j2.Rotate())
+	recsEq(t, j2.All(journal.Filter{}), 1,
+		"20240101T000000000:secret one",
+		"20240101T000001000:secret two",
+		"20240101T000001000:secret three")
+
+	seg := must(j2.Seal(context.Background()))
+	eq(t, seg.String(), "S0000000001-20240101T000000000-000000000001")
+	must(j2.Trim())
+	recsEq(t, j2.All(journal.Filter{}), 1,
+		"20240101T000000000:secret one",
+		"20240101T000001000:secret two",
+		"20240101T000001000:secret three")
+
+	// without the key, encrypted drafts cannot be continued
+	ensure(j2.WriteRecord(0, []byte("secret four")))
+	ensure(j2.FinishWriting())
+	j3 := open(t, j.clock, j.Dir, o, sealKeys{})
+	err := j3.WriteRecord(0, []byte("x"))
+	eq(t, err, journal.ErrMissingSealKey)
+	j3.FinishWriting()
+}
+
+func TestJournalEncryptRecords_turnedOn(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{})
+	ensure(j.WriteRecord(0, []byte("plain one")))
+	ensure(j.FinishWriting())
+
+	// the plaintext draft is finalized instead of being continued
+	j2 := open(t, j.clock, j.Dir, journal.Options{EncryptRecords: true})
+	ensure(j2.WriteRecord(0, []byte("secret two")))
+	ensure(j2.FinishWriting())
+
+	name := "jW0000000002-20240101T000000000-000000000002.wal"
+	deepEq(t, j2.FileNames(), []string{"jF0000000001-20240101T000000000-000000000001.wal", name})
+	ok(t, !bytes.Contains(j2.Data(name), []byte("secret")))
+	recsEq(t, j2.All(journal.Filter{}), 1,
+		"20240101T000000000:plain one",
+		"20240101T000000000:secret two")
+}
@@ -0,0 +1,129 @@
+package journal
+
+import (
+	"fmt"
+	"io"
+)
+
+// reverseState holds the records of the segment a reverse cursor is in.
+type reverseState struct {
+	table    []reverseEntry
+	arena    []byte
+	boundary uint64 // first record ID of the earliest loaded segment
+}
+
+type reverseEntry struct {
+	id         uint64
+	ts         uint64
+	start, end int
+}
+
+// ReadReverse returns a cursor that iterates over the records matching the
+// filter from newest to oldest. Offset skips the newest matching records,
+// Limit caps the number of returned records, and Latest is ignored. To load
+// older pages, use NextPage or pass the ID of the oldest record seen so far
+// minus one as MaxRecordID; only segments containing the requested records
+// are read.
+func (j *Journal) ReadReverse(filter Filter) *Cursor {
+	c := j.Read(filter)
+	c.rev = &reverseState{}
+	return c
+}
+
+func (c *Cursor) nextReverse() error {
+	rs := c.rev
+	if !c.started {
+		if err := c.start(); err != nil {
+			return err
+		}
+		var err error
+		c.segments, err = c.findSegments()
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		if c.filter.Limit > 0 && c.returned >= c.filter.Limit {
+			return io.EOF
+		}
+		if n := len(rs.table); n > 0 {
+			e := rs.table[n-1]
+			rs.table = rs.table[:n-1]
+			c.Record = Record{
+				ID:        e.id,
+				Timestamp: e.ts,
+				Data:      rs.arena[e.start:e.end:e.end],
+			}
+			if !c.admit() {
+				continue
+			}
+			c.Record.Data = c.filter.ownData(c.Record.Data)
+			return nil
+		}
+
+		n := len(c.segments)
+		if n == 0 {
+			return io.EOF
+		}
+		seg := c.segments[n-1]
+		c.segments = c.segments[:n-1]
+
+		next, err := c.loadReverseSegment(seg)
+		if err == errFileGone {
+			// sealed or trimmed in the meantime; the records we haven't
+			// returned yet now live in other files
+			c.j.resetState()
+			if rs.boundary == 1 {
+				return io.EOF
+			} else if rs.boundary > 0 {
+				c.filter.MaxRecordID = rs.boundary - 1
+			}
+			c.segments, err = c.findSegments()
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			// keep the records decoded before the error
+			if err := c.segmentError(seg, next, err); err != nil {
+				return err
+			}
+		} else if c.filter.FailOnGap && rs.boundary > next && (c.filter.MaxRecordID == 0 || next <= c.filter.MaxRecordID) {
+			return fmt.Errorf("%w: %d..%d after segment %v", ErrGap, next, rs.boundary-1, seg)
+		}
+		rs.boundary = seg.recnum
+	}
+}
+
+// loadReverseSegment decodes the matching records of the segment into the
+// offset table. Returns the first record ID that was not decoded.
+func (c *Cursor) loadReverseSegment(seg Segment) (uint64, error) {
+	rs := c.rev
+	rs.table = rs.table[:0]
+	rs.arena = rs.arena[:0]
+
+	f, sr, err := c.openSegment(seg)
+	if err != nil {
+		return seg.recnum, err
+	}
+	defer f.Close()
+
+	for {
+		err := sr.next()
+		if err == io.EOF {
+			return sr.ID + 1, nil
+		} else if err != nil {
+			return sr.ID + 1, err
+		}
+		if rs.boundary > 0 && sr.ID >= rs.boundary {
+			break // already returned from the following segment
+		}
+		if !c.filter.matches(sr.ID, sr.Timestamp) || !c.filter.matchesData(sr.ID, sr.Timestamp, sr.Data) {
+			continue
+		}
+		start := len(rs.arena)
+		rs.arena = append(rs.arena, sr.Data...)
+		rs.table = append(rs.table, reverseEntry{sr.ID, sr.Timestamp, start, len(rs.arena)})
+	}
+	return sr.ID + 1, nil
+}
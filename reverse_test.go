@@ -0,0 +1,39 @@
+package journal_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalReadReverse(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{MaxFileSize: 165})
+	writeSeq(j)
+	must(j.SealAndTrimOnce(context.Background()))
+	must(j.SealAndTrimOnce(context.Background()))
+
+	deepEq(t, collect(t, j.ReadReverse(journal.Filter{})), []string{
+		"10:ten", "9:nine", "8:eight", "7:seven", "6:six",
+		"5:five", "4:four", "3:three", "2:two", "1:one",
+	})
+
+	// "load older" pagination
+	deepEq(t, collect(t, j.ReadReverse(journal.Filter{Limit: 3})), []string{"10:ten", "9:nine", "8:eight"})
+	deepEq(t, collect(t, j.ReadReverse(journal.Filter{Limit: 3, MaxRecordID: 7})), []string{"7:seven", "6:six", "5:five"})
+	deepEq(t, collect(t, j.ReadReverse(journal.Filter{Limit: 3, MaxRecordID: 4})), []string{"4:four", "3:three", "2:two"})
+	deepEq(t, collect(t, j.ReadReverse(journal.Filter{Limit: 3, MaxRecordID: 1})), []string{"1:one"})
+
+	deepEq(t, collect(t, j.ReadReverse(journal.Filter{MinRecordID: 4, MaxTimestamp: at("20240101T000202000")})), []string{
+		"6:six", "5:five", "4:four",
+	})
+}
+
+func TestJournalReadReverse_initError(t *testing.T) {
+	j := journal.New(filepath.Join(t.TempDir(), "missing"), journal.Options{FileName: "j*.wal", Logger: testLogger(t)})
+	c := j.ReadReverse(journal.Filter{})
+	defer c.Close()
+	ok(t, !c.Next())
+	ok(t, c.Err() != nil)
+}
@@ -3,10 +3,12 @@ package journal
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"time"
 
+	"github.com/andreyvit/journal/segfile"
 	"github.com/andreyvit/sealer"
 )
 
@@ -127,23 +129,54 @@ func (j *Journal) Seal(ctx context.Context) (Segment, error) {
 
 	finalseg := tempseg
 	finalseg.status = Sealed
+
+	key, err := j.segmentSealKey(next.segnum, sealKey)
+	if err != nil {
+		return tempseg, err
+	}
+
+	outSize, err := j.writeSealedSegment(ctx, sr, tempseg, finalseg, key)
+	if err != nil {
+		if isSegmentCorruptionError(err) {
+			if qerr := j.quarantineSegment(next, err); qerr != nil {
+				return tempseg, qerr
+			}
+			return next, nil
+		}
+		return tempseg, err
+	}
+
+	elapsed := time.Since(start)
+	j.logger.Debug("journal sealed", "journal", j.debugName, "dur", elapsed.Milliseconds(), "in_size", inSize, "out_size", outSize, "ns_per_kb", (elapsed / time.Duration((inSize+1023)/1024)).Nanoseconds())
+
+	j.updateStateWithSegmentAdded(finalseg)
+	return finalseg, nil
+}
+
+// writeSealedSegment writes the remaining records of sr into tempseg using
+// the given key, and then renames tempseg to finalseg, replacing finalseg if
+// it already exists. Returns the size of the sealed file.
+func (j *Journal) writeSealedSegment(ctx context.Context, sr *segmentReader, tempseg, finalseg Segment, sealKey *sealer.Key) (int64, error) {
 	final := j.filePath(finalseg.fileName(j))
 
 	outf, err := j.openFile(tempseg, true)
 	if err != nil {
-		return tempseg, err
+		return 0, err
 	}
 	temp := outf.Name()
 
 	var ok bool
 	defer closeAndDeleteUnlessOK2(&outf, temp, &ok)
 
-	var hbuf [segmentHeaderSize]byte
-	fillSegmentHeader(hbuf[:], j, magicV1Sealed, tempseg.segnum, tempseg.ts, tempseg.recnum, sr.h.LastTimestamp, sr.h.LastRecordNumber)
+	// sealed records are written decrypted, the sealer encrypts them anyway
+	features := sr.h.Features &^ segfile.FeatureEncryption
 
-	sealw, err := sealer.Seal(outf, sealKey, hbuf[:], j.sealOpts)
+	var hbuf [segfile.HeaderSize]byte
+	fillSegmentHeader(&hbuf, j, segfile.MagicSealed, tempseg.segnum, tempseg.ts, tempseg.recnum, sr.h.LastTimestamp, sr.h.LastRecordNumber, sr.h.SegmentInvariant, features)
+
+	sealw, err := sealer.Seal(outf, sealKey, segfile.AppendExtension(hbuf[:], features, &sr.ext), j.sealOpts)
 	if err != nil {
-		return tempseg, err
+		return 0, err
 	}
 
 	ts := tempseg.ts
@@ -153,62 +186,50 @@ func (j *Journal) Seal(ctx context.Context) (Segment, error) {
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			if isSegmentCorruptionError(err) {
-				if qerr := j.quarantineSegment(next, err); qerr != nil {
-					return tempseg, qerr
-				}
-				return next, nil
-			}
-			return tempseg, err
+			return 0, err
 		}
 
 		var tsDelta uint64
-		if sr.ts > ts {
-			tsDelta = sr.ts - ts
-			ts = sr.ts
+		if sr.Timestamp > ts {
+			tsDelta = sr.Timestamp - ts
+			ts = sr.Timestamp
 		}
 
-		err = writeSealedRecord(sealw, tsDelta, sr.data)
+		err = writeSealedRecord(sealw, tsDelta, sr.Data)
 		if err != nil {
-			return tempseg, err
+			return 0, err
 		}
 
 		count++
 		if count%10 == 0 {
 			if err := ctx.Err(); err != nil {
-				return tempseg, err
+				return 0, err
 			}
 		}
 	}
 
 	err = sealw.Close()
 	if err != nil {
-		return tempseg, err
+		return 0, err
 	}
 
 	outSize, err := outf.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return tempseg, err
+		return 0, err
 	}
 
 	err = outf.Close()
 	outf = nil // prevent double close in closeAndDeleteUnlessOK2
 	if err != nil {
-		return tempseg, err
+		return 0, err
 	}
 	ok = true
 
 	err = os.Rename(temp, final)
 	if err != nil {
-		return tempseg, err
+		return 0, err
 	}
-
-	elapsed := time.Since(start)
-	j.logger.Debug("journal sealed", "journal", j.debugName, "dur", elapsed.Milliseconds(), "in_size", inSize, "out_size", outSize, "ns_per_kb", (elapsed / time.Duration((inSize+1023)/1024)).Nanoseconds())
-
-	ok = true
-	j.updateStateWithSegmentAdded(finalseg)
-	return finalseg, nil
+	return outSize, nil
 }
 
 func (j *Journal) Trim() (Segment, error) {
@@ -236,6 +257,12 @@ func (j *Journal) Trim() (Segment, error) {
 	if next.IsZero() || !next.status.CanSeal() {
 		return Segment{}, nil
 	}
+	if j.retainUnacked {
+		acked, err := j.isSegmentAcknowledged(next)
+		if err != nil || !acked {
+			return Segment{}, err
+		}
+	}
 
 	err = j.deleteSegment(next)
 	if err != nil {
@@ -246,9 +273,134 @@ func (j *Journal) Trim() (Segment, error) {
 	return next, nil
 }
 
+// Reseal rewrites sealed segments matching the filter that were sealed with
+// a key other than the current one (the first of Options.SealKeys), so that
+// retired keys can eventually be removed. Segments already sealed with the
+// current key are skipped, so an interrupted Reseal can simply be rerun.
+// Shredded segments are skipped too, as there is nothing left to reseal.
+// Returns the number of segments resealed.
+func (j *Journal) Reseal(ctx context.Context, filter Filter) (int, error) {
+	if !j.CanSeal() {
+		return 0, nil
+	}
+	sealKey := j.sealKeys[0]
+
+	segs, err := j.findAllSealedSegments(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	// Unlike Seal, Reseal is not opportunistic, so wait for the lock.
+	j.sealLock.Lock()
+	defer j.sealLock.Unlock()
+
+	var count int
+	for _, seg := range segs {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		resealed, err := j.resealSegment(ctx, seg, sealKey)
+		if err != nil {
+			return count, err
+		}
+		if resealed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (j *Journal) resealSegment(ctx context.Context, seg Segment, sealKey *sealer.Key) (bool, error) {
+	inf, sr, err := openSegment(j, seg)
+	if err == errFileGone || errors.Is(err, ErrShredded) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer inf.Close()
+
+	if isDataKeyID(sr.keyID) {
+		// data key only needs to be rewrapped under the current key
+		return j.rewrapDataKey(sr.keyID, sealKey)
+	}
+	if sr.keyID == sealKey.ID && !j.perSegmentKeys {
+		return false, nil
+	}
+
+	tempseg := seg
+	tempseg.status = sealingTemp
+	j.setSealingTemp(tempseg)
+	defer j.setSealingTemp(Segment{})
+
+	key, err := j.segmentSealKey(seg.segnum, sealKey)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = j.writeSealedSegment(ctx, sr, tempseg, seg, key)
+	if err != nil {
+		return false, err
+	}
+	j.logger.Info("journal resealed", "journal", j.debugName, "segment", seg.String(), "old_key", fmt.Sprintf("%x", sr.keyID))
+	return true, nil
+}
+
+// segmentSealKey returns the key to seal the given segment with: either the
+// seal key itself, or a new data key when using per-segment keys.
+func (j *Journal) segmentSealKey(segnum uint64, sealKey *sealer.Key) (*sealer.Key, error) {
+	if j.perSegmentKeys {
+		return j.newDataKey(segnum, sealKey)
+	}
+	return sealKey, nil
+}
+
+// Shred makes the contents of sealed segments that may contain records
+// matching the filter permanently unreadable by destroying their per-segment
+// data keys, and deletes unsealed copies of those segments. Whole segments
+// are shredded, including any records outside of the filter range. Segments
+// that have not been sealed yet are not affected. Returns ErrNotShreddable,
+// without shredding anything, if some of the matching sealed segments
+// weren't sealed with a per-segment key (see Options.PerSegmentKeys).
+//
+// Reading a shredded segment fails with ErrShredded.
+func (j *Journal) Shred(filter Filter) ([]Segment, error) {
+	segs, err := j.findOverlappingSealedSegments(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(segs) == 0 {
+		return nil, nil
+	}
+
+	j.sealLock.Lock()
+	defer j.sealLock.Unlock()
+
+	segnums := make([]uint64, len(segs))
+	for i, seg := range segs {
+		segnums[i] = seg.segnum
+	}
+	err = j.shredDataKeys(segnums)
+	if err != nil {
+		return nil, err
+	}
+	j.gets.clear() // cached readers hold decrypted streams
+	j.logger.Info("journal shredded segments", "journal", j.debugName, "first", segs[0].String(), "last", segs[len(segs)-1].String(), "count", len(segs))
+
+	for _, seg := range segs {
+		unsealed := seg
+		unsealed.status = Finalized
+		err := j.deleteSegment(unsealed)
+		if err != nil && !os.IsNotExist(err) {
+			return segs, err
+		}
+		j.updateStateWithSegmentGone(unsealed)
+	}
+	return segs, nil
+}
+
 func writeSealedRecord(w io.Writer, tsDelta uint64, data []byte) error {
-	var hbuf [maxRecHeaderLen]byte
-	h := appendSealedRecordHeader(hbuf[:0], len(data), tsDelta)
+	var hbuf [segfile.MaxRecordHeaderSize]byte
+	h := segfile.AppendSealedRecordHeader(hbuf[:0], len(data), tsDelta)
 
 	_, err := w.Write(h)
 	if err != nil {
@@ -3,6 +3,7 @@ package journal_test
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/andreyvit/journal"
+	"github.com/andreyvit/sealer"
 )
 
 func TestJournalSeal_simple(t *testing.T) {
@@ -397,3 +399,101 @@ func writeN(j *testJournal, n int) {
 		j.clock.Advance(1 * time.Second)
 	}
 }
+
+func TestJournalReseal(t *testing.T) {
+	ctx := context.Background()
+	j := setupWritable(t, newClock(), journal.Options{
+		MaxFileSize: 165,
+	})
+	writeSeq(j)
+	must(j.SealAndTrimAll(ctx))
+
+	newKey := &sealer.Key{ID: [32]byte{'Y'}, Key: [32]byte{42}}
+	j2 := open(t, j.clock, j.Dir, journal.Options{MaxFileSize: 165}, sealKeys{newKey, sealKey})
+	eq(t, must(j2.Reseal(ctx, journal.Filter{MaxRecordID: 4})), 2)
+	eq(t, must(j2.Reseal(ctx, journal.Filter{MaxRecordID: 4})), 0) // idempotent
+	eq(t, must(j2.Reseal(ctx, journal.Filter{})), 2)
+	eq(t, must(j2.Reseal(ctx, journal.Filter{})), 0)
+	deepEq(t, j2.FileNames(), []string{
+		"jS0000000001-20240101T000000000-000000000001.wal",
+		"jS0000000002-20240101T000002000-000000000003.wal",
+		"jS0000000003-20240101T000022000-000000000005.wal",
+		"jS0000000004-20240101T000342000-000000000007.wal",
+		"jW0000000005-20240101T020342000-000000000009.wal",
+	})
+
+	// old key is no longer needed
+	j3 := open(t, j.clock, j.Dir, journal.Options{MaxFileSize: 165}, sealKeys{newKey})
+	recsEq(t, j3.All(journal.Filter{}), 1,
+		"20240101T000000000:one",
+		"20240101T000001000:two",
+		"20240101T000002000:three",
+		"20240101T000012000:four",
+		"20240101T000022000:five",
+		"20240101T000202000:six",
+		"20240101T000342000:seven",
+		"20240101T010342000:eight",
+		"20240101T020342000:nine",
+		"20240101T030342000:ten")
+}
+
+func TestJournalShred(t *testing.T) {
+	ctx := context.Background()
+	j := setupWritable(t, newClock(), journal.Options{
+		MaxFileSize:    165,
+		PerSegmentKeys: true,
+	})
+	writeSeq(j)
+	ensure(j.Rotate())
+	eq(t, must(j.Seal(ctx)).String(), "S0000000001-20240101T000000000-000000000001")
+	eq(t, must(j.Seal(ctx)).String(), "S0000000002-20240101T000002000-000000000003")
+	eq(t, must(j.Seal(ctx)).String(), "S0000000003-20240101T000022000-000000000005")
+
+	segs := must(j.Shred(journal.Filter{MinRecordID: 3, MaxRecordID: 4}))
+	eq(t, len(segs), 1)
+	eq(t, segs[0].String(), "S0000000002-20240101T000002000-000000000003")
+	deepEq(t, j.FileNames(), []string{
+		"jF0000000001-20240101T000000000-000000000001.wal",
+		"jS0000000001-20240101T000000000-000000000001.wal",
+		"jS0000000002-20240101T000002000-000000000003.wal",
+		"jF0000000003-20240101T000022000-000000000005.wal",
+		"jS0000000003-20240101T000022000-000000000005.wal",
+		"jF0000000004-20240101T000342000-000000000007.wal",
+		"jF0000000005-20240101T020342000-000000000009.wal",
+		".jkeys.wal",
+	})
+
+	recsEq(t, j.All(journal.Filter{MaxRecordID: 2}), 1,
+		"20240101T000000000:one",
+		"20240101T000001000:two")
+
+	var err error
+	for range j.Records(journal.Filter{MinRecordID: 3}, func(e error) { err = e }) {
+	}
+	eq(t, err, journal.ErrShredded)
+
+	// rotating the seal key rewraps data keys without rewriting segments
+	before := j.Data("jS0000000001-20240101T000000000-000000000001.wal")
+	newKey := &sealer.Key{ID: [32]byte{'Y'}, Key: [32]byte{42}}
+	j2 := open(t, j.clock, j.Dir, journal.Options{MaxFileSize: 165, PerSegmentKeys: true}, sealKeys{newKey, sealKey})
+	eq(t, must(j2.Reseal(ctx, journal.Filter{MaxRecordID: 2})), 1)
+	eq(t, must(j2.Reseal(ctx, journal.Filter{MaxRecordID: 2})), 0)
+	bytesEq(t, j2.Data("jS0000000001-20240101T000000000-000000000001.wal"), before)
+	eq(t, must(j2.Reseal(ctx, journal.Filter{})), 1) // skips the shredded segment 2
+	eq(t, must(j2.Reseal(ctx, journal.Filter{})), 0)
+
+	j3 := open(t, j.clock, j.Dir, journal.Options{MaxFileSize: 165}, sealKeys{newKey})
+	recsEq(t, j3.All(journal.Filter{MaxRecordID: 2}), 1,
+		"20240101T000000000:one",
+		"20240101T000001000:two")
+	recsEq(t, j3.All(journal.Filter{MinRecordID: 5, MinTimestamp: at("20240101T000022000"), MaxRecordID: 6}), 5,
+		"20240101T000022000:five",
+		"20240101T000202000:six")
+
+	// segments sealed without per-segment keys cannot be shredded
+	u := setupWritable(t, newClock(), journal.Options{MaxFileSize: 165})
+	writeSeq(u)
+	must(u.Seal(ctx))
+	_, err = u.Shred(journal.Filter{})
+	ok(t, errors.Is(err, journal.ErrNotShreddable))
+}
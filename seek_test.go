@@ -0,0 +1,53 @@
+package journal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestCursorSeek(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{MaxFileSize: 165})
+	writeSeq(j)
+	must(j.SealAndTrimOnce(context.Background()))
+
+	next := func(c *journal.Cursor) string {
+		t.Helper()
+		if !c.Next() {
+			ensure(c.Err())
+			return "EOF"
+		}
+		return fmt.Sprintf("%d:%s", c.ID, c.Data)
+	}
+
+	c := j.Read(journal.Filter{})
+	defer c.Close()
+	eq(t, next(c), "1:one")
+	ensure(c.SeekID(6))
+	eq(t, next(c), "6:six")
+	ensure(c.SeekID(7)) // within the open segment
+	eq(t, next(c), "7:seven")
+	ensure(c.SeekID(2)) // backwards into a sealed segment
+	eq(t, next(c), "2:two")
+	eq(t, next(c), "3:three")
+	ensure(c.SeekTime(journal.ToTime(at("20240101T000342000"))))
+	eq(t, next(c), "7:seven")
+	ensure(c.SeekTime(journal.ToTime(at("20240101T000343000"))))
+	eq(t, next(c), "8:eight")
+	ensure(c.SeekID(10))
+	eq(t, next(c), "10:ten")
+	eq(t, next(c), "EOF")
+	ensure(c.SeekID(9)) // after reaching the end
+	eq(t, next(c), "9:nine")
+
+	r := j.ReadReverse(journal.Filter{})
+	defer r.Close()
+	eq(t, next(r), "10:ten")
+	ensure(r.SeekID(4))
+	eq(t, next(r), "4:four")
+	eq(t, next(r), "3:three")
+	ensure(r.SeekTime(journal.ToTime(at("20240101T000202000"))))
+	eq(t, next(r), "6:six")
+}
@@ -0,0 +1,63 @@
+package journal
+
+// Action tells a cursor what to do about a segment it cannot read; see
+// Filter.OnSegmentError.
+type Action int
+
+const (
+	// StopReading fails the cursor with the error.
+	StopReading Action = iota
+
+	// SkipSegment skips the unread records of the segment.
+	SkipSegment
+
+	// QuarantineSegment moves the segment file to the trash directory, and
+	// skips the unread records of the segment.
+	QuarantineSegment
+)
+
+// SkippedRange describes records that a cursor did not deliver because
+// Filter.OnSegmentError skipped the rest of a segment.
+type SkippedRange struct {
+	Segment     Segment
+	MinRecordID uint64
+	MaxRecordID uint64 // 0 if unknown, i.e. the segment was the last one
+	Err         error
+}
+
+// Skipped returns the record ranges skipped so far; see
+// Filter.OnSegmentError. The ranges cover all undelivered records of the
+// skipped segments, whether or not they match the filter, and are empty
+// (MinRecordID > MaxRecordID) if a segment failed after its last record.
+func (c *Cursor) Skipped() []SkippedRange {
+	return c.skippedRanges
+}
+
+// segmentError handles a failure to read the records of seg starting with
+// ID from. Returns nil if the cursor should carry on with the next segment.
+func (c *Cursor) segmentError(seg Segment, from uint64, err error) error {
+	if c.filter.OnSegmentError == nil {
+		return err
+	}
+	switch c.filter.OnSegmentError(seg, err) {
+	case SkipSegment:
+	case QuarantineSegment:
+		if qerr := c.j.quarantineSegment(seg, err); qerr != nil {
+			return qerr
+		}
+	default:
+		return err
+	}
+
+	next, ferr := c.j.followingSegment(seg)
+	if ferr != nil {
+		return ferr
+	}
+	r := SkippedRange{Segment: seg, MinRecordID: from, Err: err}
+	if next.IsNonZero() {
+		r.MaxRecordID = next.recnum - 1
+	}
+	c.skippedRanges = append(c.skippedRanges, r)
+	c.ended = 0 // the skipped records are not a gap
+	return nil
+}
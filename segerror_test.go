@@ -0,0 +1,106 @@
+package journal_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalRead_onSegmentError(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+	j.StartWriting()
+	must(j.SealAndTrimAll(context.Background()))
+
+	// damage the second sealed segment
+	name := "jS0000000002-20240101T000002000-000000000003.wal"
+	data := j.Data(name)
+	for i := 200; i < len(data); i++ {
+		data[i] ^= 0xFF
+	}
+	ensure(os.WriteFile(filepath.Join(j.Dir, name), data, 0o644))
+
+	c := j.Read(journal.Filter{})
+	collected := func(c *journal.Cursor) []string {
+		var result []string
+		for c.Next() {
+			result = append(result, string(c.Data))
+		}
+		return result
+	}
+	deepEq(t, collected(c), []string{"one", "two"})
+	ok(t, c.Err() != nil)
+	c.Close()
+
+	var failed []uint64
+	skip := func(seg journal.Segment, err error) journal.Action {
+		failed = append(failed, seg.SegmentNumber())
+		return journal.SkipSegment
+	}
+	all := []string{"1:one", "2:two", "5:five", "6:six", "7:seven", "8:eight", "9:nine", "10:ten"}
+	for _, filter := range []journal.Filter{{}, {Prefetch: 2}} {
+		failed = nil
+		filter.OnSegmentError = skip
+		c = j.Read(filter)
+		deepEq(t, collect(t, c), all)
+		deepEq(t, failed, []uint64{2})
+		skipped := c.Skipped()
+		eq(t, len(skipped), 1)
+		eq(t, skipped[0].Segment.SegmentNumber(), 2)
+		eq(t, skipped[0].MinRecordID, 3)
+		eq(t, skipped[0].MaxRecordID, 4)
+		ok(t, skipped[0].Err != nil)
+	}
+
+	c = j.ReadReverse(journal.Filter{OnSegmentError: skip})
+	deepEq(t, collect(t, c), []string{"10:ten", "9:nine", "8:eight", "7:seven", "6:six", "5:five", "2:two", "1:one"})
+	eq(t, len(c.Skipped()), 1)
+
+	// quarantine moves the file out of the way
+	c = j.Read(journal.Filter{OnSegmentError: func(journal.Segment, error) journal.Action {
+		return journal.QuarantineSegment
+	}})
+	deepEq(t, collect(t, c), all)
+	_, err := os.Stat(filepath.Join(j.Dir, "trash", name))
+	ensure(err)
+	deepEq(t, collect(t, j.Read(journal.Filter{})), all)
+
+	// missing seal keys
+	k := open(t, j.clock, j.Dir, journal.Options{}, sealKeys{}, nonVerbose)
+	c = k.Read(journal.Filter{OnSegmentError: func(seg journal.Segment, err error) journal.Action {
+		ok(t, errors.Is(err, journal.ErrMissingSealKey))
+		return journal.SkipSegment
+	}})
+	deepEq(t, collect(t, c), []string{"9:nine", "10:ten"})
+	eq(t, len(c.Skipped()), 3)
+}
+
+func TestJournalRead_onSegmentError_sealedMeanwhile(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{}, nonVerbose)
+	writeSeq(j)
+	j.StartWriting()
+
+	var failed []uint64
+	c := j.Read(journal.Filter{OnSegmentError: func(seg journal.Segment, err error) journal.Action {
+		failed = append(failed, seg.SegmentNumber())
+		return journal.SkipSegment
+	}})
+	defer c.Close()
+	ok(t, c.Next())
+	eq(t, string(c.Data), "one")
+
+	// the unsealed files the cursor hasn't opened yet get sealed and trimmed
+	must(j.SealAndTrimAll(context.Background()))
+	var actual []string
+	for c.Next() {
+		actual = append(actual, string(c.Data))
+	}
+	ensure(c.Err())
+	deepEq(t, actual, []string{"two", "three", "four", "five", "six", "seven", "eight", "nine", "ten"})
+	deepEq(t, failed, []uint64(nil))
+	eq(t, len(c.Skipped()), 0)
+}
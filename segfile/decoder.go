@@ -0,0 +1,350 @@
+package segfile
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Decoder decodes the records that follow the header and the extension.
+//
+// Fields describe the last record returned by Next and the last decoded
+// commit; they must not be modified by callers.
+type Decoder struct {
+	ID        uint64
+	Timestamp uint64
+	Data      []byte // valid until the next call to Next
+	Offset    int64  // offset of the record
+	End       int64  // offset just past the record
+
+	// Chain is the hash chain value after the record, when
+	// UsesChain(Header.Features).
+	Chain [ChainHashSize]byte
+
+	Size    int64 // offset just past the last decoded item
+	Records int   // number of records decoded so far
+
+	CommittedID        uint64
+	CommittedTimestamp uint64
+	CommittedSize      int64
+	CommittedChain     [ChainHashSize]byte
+
+	r        *bufio.Reader
+	sealed   bool
+	segnum   uint64
+	aead     cipher.AEAD
+	chained  bool
+	hasher   hash.Hash
+	dataHash xxhash.Digest
+	rawData  []byte
+
+	recHash xxhash.Digest // dataHash just past the returned record
+	resumed bool          // no commit verified since ResumeDecoder
+
+	// state of the last decoded record
+	id     uint64
+	ts     uint64
+	chain  [ChainHashSize]byte
+	data   []byte
+	offset int64
+
+	committedOnly bool
+	pending       []bufferedRecord
+	pendingData   []byte
+	ready         []bufferedRecord
+	readyData     []byte
+}
+
+type bufferedRecord struct {
+	id         uint64
+	ts         uint64
+	offset     int64
+	endOffset  int64
+	chain      [ChainHashSize]byte
+	dataHash   xxhash.Digest
+	start, end int
+}
+
+// ResumeState is what a decoder needs to continue right after a record of
+// an unsealed file; see Decoder.ResumeState.
+type ResumeState struct {
+	End       int64
+	ID        uint64
+	Timestamp uint64
+	DataHash  xxhash.Digest
+	Chain     [ChainHashSize]byte
+}
+
+// NewDecoder returns a decoder of the records read from r, which must be
+// positioned just past the extension (for sealed files, r must yield the
+// decrypted sealer stream). offset is the offset of the first record, and
+// aead is required for FeatureEncryption.
+func NewDecoder(r io.Reader, h *Header, ext *Extension, offset int64, aead cipher.AEAD) *Decoder {
+	d := &Decoder{
+		ID:            h.FirstRecordNumber - 1,
+		Timestamp:     h.FirstTimestamp,
+		Offset:        offset,
+		End:           offset,
+		Size:          offset,
+		CommittedSize: offset,
+		r:             bufio.NewReader(r),
+		sealed:        h.IsSealed(),
+		segnum:        h.SegmentNumber,
+		aead:          aead,
+		id:            h.FirstRecordNumber - 1,
+		ts:            h.FirstTimestamp,
+	}
+	d.dataHash.Reset()
+	if UsesChain(h.Features) {
+		d.chained = true
+		d.chain = ext.PrevChainHash
+		d.Chain = d.chain
+		d.CommittedChain = d.chain
+		d.hasher = sha256.New()
+	}
+	d.recHash = d.dataHash
+	return d
+}
+
+// ResumeDecoder returns a decoder that continues after the record described
+// by st, reading from r positioned at st.End of an unsealed file. The next
+// commit verifies that st matches the file; if it does not, Next returns
+// ErrResumeMismatch.
+func ResumeDecoder(r io.Reader, h *Header, st *ResumeState, aead cipher.AEAD) *Decoder {
+	d := &Decoder{
+		ID:                 st.ID,
+		Timestamp:          st.Timestamp,
+		Offset:             st.End,
+		End:                st.End,
+		Chain:              st.Chain,
+		Size:               st.End,
+		Records:            1,
+		CommittedID:        st.ID,
+		CommittedTimestamp: st.Timestamp,
+		CommittedSize:      st.End,
+		CommittedChain:     st.Chain,
+		r:                  bufio.NewReader(r),
+		sealed:             h.IsSealed(),
+		segnum:             h.SegmentNumber,
+		aead:               aead,
+		dataHash:           st.DataHash,
+		recHash:            st.DataHash,
+		id:                 st.ID,
+		ts:                 st.Timestamp,
+		chain:              st.Chain,
+		resumed:            true,
+	}
+	if UsesChain(h.Features) {
+		d.chained = true
+		d.hasher = sha256.New()
+	}
+	return d
+}
+
+// ResumeState returns the state needed to continue decoding right after the
+// last record returned by Next.
+func (d *Decoder) ResumeState() ResumeState {
+	return ResumeState{
+		End:       d.End,
+		ID:        d.ID,
+		Timestamp: d.Timestamp,
+		DataHash:  d.recHash,
+		Chain:     d.Chain,
+	}
+}
+
+// SetCommittedOnly makes Next return the records of an unsealed file only
+// once the commit that covers them has been decoded and verified. Records
+// after the last valid commit are never returned. This is meant for reading
+// a draft that is being appended to.
+func (d *Decoder) SetCommittedOnly() {
+	d.committedOnly = true
+}
+
+// DataHash returns the running checksum of all items decoded so far, which
+// the next commit of an unsealed file must match.
+func (d *Decoder) DataHash() xxhash.Digest {
+	return d.dataHash
+}
+
+func (d *Decoder) corrupted(offset int64, format string, args ...any) error {
+	return &CorruptionError{Offset: offset, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (d *Decoder) truncated(offset int64, format string, args ...any) error {
+	return &CorruptionError{Offset: offset, Reason: fmt.Sprintf(format, args...), Truncated: true}
+}
+
+// Next decodes the next record, skipping and verifying commits. Returns
+// io.EOF at the end of a cleanly committed file, and *CorruptionError if the
+// file is corrupted or ends with uncommitted data.
+func (d *Decoder) Next() error {
+	if !d.committedOnly || d.sealed {
+		isRecord, err := d.nextItem()
+		for err == nil && !isRecord {
+			isRecord, err = d.nextItem()
+		}
+		if err != nil {
+			return err
+		}
+		d.ID, d.Timestamp, d.Data, d.Offset, d.End, d.Chain = d.id, d.ts, d.data, d.offset, d.Size, d.chain
+		d.recHash = d.dataHash
+		return nil
+	}
+
+	for len(d.ready) == 0 {
+		isRecord, err := d.nextItem()
+		if err != nil {
+			return err
+		}
+		if isRecord {
+			start := len(d.pendingData)
+			d.pendingData = append(d.pendingData, d.data...)
+			d.pending = append(d.pending, bufferedRecord{d.id, d.ts, d.offset, d.Size, d.chain, d.dataHash, start, len(d.pendingData)})
+		} else {
+			d.ready, d.pending = d.pending, d.ready[:0]
+			d.readyData, d.pendingData = d.pendingData, d.readyData[:0]
+		}
+	}
+	rec := &d.ready[0]
+	d.ID, d.Timestamp, d.Data, d.Offset, d.End, d.Chain = rec.id, rec.ts, d.readyData[rec.start:rec.end:rec.end], rec.offset, rec.endOffset, rec.chain
+	d.recHash = rec.dataHash
+	d.ready = d.ready[1:]
+	return nil
+}
+
+// nextItem decodes a single record or commit.
+func (d *Decoder) nextItem() (bool, error) {
+	isUnsealed := !d.sealed
+	b, err := d.r.Peek(MaxRecordHeaderSize)
+	eof := err == io.EOF
+	if eof {
+		if len(b) == 0 {
+			// end of file; was there a commit?
+			if !isUnsealed || d.Size == d.CommittedSize {
+				return false, io.EOF
+			}
+			return false, d.truncated(d.CommittedSize, "end of file without a commit")
+		}
+	} else if err != nil {
+		return false, err
+	}
+	if isUnsealed && (b[0]&RecordFlagCommit != 0) {
+		var b [8]byte
+		_, err := io.ReadFull(d.r, b[:])
+		if err == io.ErrUnexpectedEOF {
+			return false, d.truncated(d.Size, "end of file in the middle of commit")
+		} else if err != nil {
+			return false, err
+		}
+		actual := binary.LittleEndian.Uint64(b[:])
+		expected := CommitChecksum(&d.dataHash)
+		d.dataHash.Write(b[:])
+		if actual != expected {
+			if d.resumed {
+				return false, fmt.Errorf("%w: commit checksum %08x at offset %d, expected %08x", ErrResumeMismatch, actual, d.Size, expected)
+			}
+			return false, d.corrupted(d.Size, "commit checksum %08x, expected %08x", actual, expected)
+		}
+		if d.Records == 0 {
+			return false, d.corrupted(d.Size, "commit without a prior record")
+		}
+
+		d.Size += 8
+		d.resumed = false
+		d.CommittedID = d.id
+		d.CommittedTimestamp = d.ts
+		d.CommittedSize = d.Size
+		d.CommittedChain = d.chain
+		return false, nil
+	}
+
+	rawSize, n1 := binary.Uvarint(b)
+	if n1 == 0 && eof {
+		return false, d.truncated(d.Size, "end of file in the middle of record size")
+	} else if n1 <= 0 {
+		return false, d.corrupted(d.Size, "cannot decode record size")
+	}
+	var dataSize int
+	if isUnsealed {
+		dataSize = int(rawSize / 2)
+	} else {
+		dataSize = int(rawSize)
+	}
+
+	tsdelta, n2 := binary.Uvarint(b[n1:])
+	if n2 == 0 && eof {
+		return false, d.truncated(d.Size, "end of file in the middle of record timestamp")
+	} else if n2 <= 0 {
+		return false, d.corrupted(d.Size, "cannot decode record timestamp")
+	}
+
+	n := n1 + n2
+	if isUnsealed {
+		d.dataHash.Write(b[:n])
+	}
+	d.r.Discard(n)
+
+	buf := &d.data
+	if d.aead != nil {
+		buf = &d.rawData
+	}
+	if cap(*buf) < dataSize {
+		*buf = make([]byte, dataSize, allocSize(dataSize))
+	} else {
+		*buf = (*buf)[:dataSize]
+	}
+
+	_, err = io.ReadFull(d.r, *buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return false, d.truncated(d.Size, "end of file in the middle of %d-byte record data", dataSize)
+	} else if err != nil {
+		return false, err
+	}
+
+	d.offset = d.Size
+	d.Records++
+	d.id++
+	d.ts += tsdelta
+	d.Size += int64(n + dataSize)
+
+	if isUnsealed {
+		d.dataHash.Write(*buf)
+	}
+	if d.aead != nil {
+		var ok bool
+		d.data, ok = DecryptRecord(d.aead, d.data, d.segnum, d.id, d.ts, d.rawData)
+		if !ok {
+			return false, d.corrupted(d.offset, "cannot decrypt record %d", d.id)
+		}
+	}
+	if d.chained {
+		ChainRecord(d.hasher, &d.chain, d.id, d.ts, d.data)
+	}
+
+	if !isUnsealed {
+		d.CommittedID = d.id
+		d.CommittedTimestamp = d.ts
+		d.CommittedSize = d.Size
+		d.CommittedChain = d.chain
+	}
+	return true, nil
+}
+
+func allocSize(sz int) int {
+	if sz >= math.MaxInt64/2 {
+		panic("size too large")
+	}
+	r := 64 * 1024
+	for r < sz {
+		r <<= 1
+	}
+	return r
+}
@@ -0,0 +1,304 @@
+// Package segfile implements the on-disk format of journal segment files.
+//
+// The journal package uses it internally; it is exported for forensic and
+// migration tools that need to inspect individual segment files without a
+// Journal. See the journal package documentation for an overview of the
+// format.
+//
+// Segment file:
+//
+//   - file = header extension item*
+//   - header = (see Header)
+//   - extension = (see Extension; blocks present according to Header.Features)
+//   - item = record | commit
+//   - record = (size << 1):uvarint timestamp_delta:uvarint data
+//   - commit = checksum:64 (with bit 0 set, i.e. checksum | 1)
+//
+// Sealed files keep the header and the extension in plain text, followed by
+// the sealer stream of
+//
+//   - record = size:uvarint timestamp_delta:uvarint data
+package segfile
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/andreyvit/sealer"
+	"github.com/cespare/xxhash/v2"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported journal version")
+	ErrCorrupted          = errors.New("corrupted journal segment file")
+	ErrResumeMismatch     = errors.New("journal segment does not match the resume state")
+)
+
+// CorruptionError describes the exact location of a corruption. Offsets of
+// unsealed files are file offsets; offsets of sealed files are offsets within
+// the decrypted record stream.
+type CorruptionError struct {
+	Offset int64
+	Reason string
+
+	// Truncated is set when the file ends in the middle of an item, as it
+	// does while the item is still being written.
+	Truncated bool
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("%v at offset %d: %s", ErrCorrupted, e.Offset, e.Reason)
+}
+
+func (e *CorruptionError) Unwrap() error {
+	return ErrCorrupted
+}
+
+const (
+	RecordFlagCommit byte = 1
+	recordFlagShift       = 1
+)
+
+const (
+	MagicDraft     = uint64('J')<<0 | uint64('O')<<8 | uint64('U')<<16 | uint64('R')<<24 | uint64('N')<<32 | uint64('L')<<40 | uint64('A')<<48 | uint64('D')<<56
+	MagicFinalized = uint64('J')<<0 | uint64('O')<<8 | uint64('U')<<16 | uint64('R')<<24 | uint64('N')<<32 | uint64('L')<<40 | uint64('A')<<48 | uint64('F')<<56
+	MagicSealed    = uint64('J')<<0 | uint64('O')<<8 | uint64('U')<<16 | uint64('R')<<24 | uint64('N')<<32 | uint64('L')<<40 | uint64('A')<<48 | uint64('S')<<56
+)
+
+type Header struct {
+	Magic             uint64   // offset 0
+	SegmentNumber     uint64   // offset 8
+	FirstTimestamp    uint64   // offset 16
+	FirstRecordNumber uint64   // offset 24
+	LastTimestamp     uint64   // offset 32
+	LastRecordNumber  uint64   // offset 40
+	JournalInvariant  [32]byte // offset 48
+	SegmentInvariant  [32]byte // offset 80
+	Features          uint64   // offset 112
+	HeaderChecksum    uint64   // offset 120
+} // size 128
+
+const HeaderSize = 128
+
+// Optional features recorded in Header.Features. Each feature adds
+// a fixed-size block to the segment extension that immediately follows
+// the header; blocks are laid out in the order of feature bits.
+const (
+	FeatureHashChain uint64 = 1 << iota
+	FeatureSignature
+	FeatureEncryption
+
+	KnownFeatures = FeatureHashChain | FeatureSignature | FeatureEncryption
+)
+
+const ChainHashSize = sha256.Size
+
+const (
+	chainExtensionSize      = 2 * ChainHashSize
+	signatureExtensionSize  = ChainHashSize + ed25519.PublicKeySize + ed25519.SignatureSize
+	encryptionExtensionSize = sealer.IDSize + 32
+)
+
+const signatureDomain = "journal segment signature v1\x00"
+
+// MaxRecordHeaderSize is the maximum size of the size and timestamp prefix
+// of a record.
+const MaxRecordHeaderSize = binary.MaxVarintLen64 /* sizeAndFlag */ + binary.MaxVarintLen64 /* timestamp */
+
+// Extension holds the contents of the optional header blocks.
+type Extension struct {
+	PrevChainHash  [ChainHashSize]byte // final chain hash of the previous segment
+	FinalChainHash [ChainHashSize]byte // zero until the segment is finalized
+
+	ContentDigest [ChainHashSize]byte // final record chain value of a signed segment
+	SignerKey     [ed25519.PublicKeySize]byte
+	Signature     [ed25519.SignatureSize]byte
+
+	RecordKeyID   [sealer.IDSize]byte // seal key the record key is derived from
+	RecordKeySalt [32]byte
+}
+
+// IsSealed tells whether the header belongs to a sealed file.
+func (h *Header) IsSealed() bool {
+	return h.Magic == MagicSealed
+}
+
+// Encode writes the header into buf, computing HeaderChecksum.
+func (h *Header) Encode(buf *[HeaderSize]byte) {
+	n, err := binary.Encode(buf[:], binary.LittleEndian, h)
+	if err != nil {
+		panic(err)
+	}
+	if n != HeaderSize {
+		panic("internal size mismatch")
+	}
+	h.HeaderChecksum = HeaderChecksum(buf)
+	binary.LittleEndian.PutUint64(buf[HeaderSize-8:], h.HeaderChecksum)
+}
+
+// DecodeHeader decodes buf without validating it; see Header.Validate.
+func DecodeHeader(buf *[HeaderSize]byte) Header {
+	var h Header
+	n, err := binary.Decode(buf[:], binary.LittleEndian, &h)
+	if err != nil {
+		panic(err)
+	}
+	if n != HeaderSize {
+		panic("internal size mismatch")
+	}
+	return h
+}
+
+// HeaderChecksum computes the expected checksum of the encoded header.
+func HeaderChecksum(buf *[HeaderSize]byte) uint64 {
+	return xxhash.Sum64(buf[:HeaderSize-8])
+}
+
+// Validate checks the magic, the checksum and the features of a header
+// decoded from buf.
+func (h *Header) Validate(buf *[HeaderSize]byte) error {
+	if h.Magic != MagicDraft && h.Magic != MagicFinalized && h.Magic != MagicSealed {
+		return ErrUnsupportedVersion
+	}
+	if checksum := HeaderChecksum(buf); checksum != h.HeaderChecksum {
+		return &CorruptionError{Offset: HeaderSize - 8, Reason: fmt.Sprintf("header checksum %08x, expected %08x", h.HeaderChecksum, checksum)}
+	}
+	if h.Features&^KnownFeatures != 0 {
+		return fmt.Errorf("%w: unknown features %x", ErrUnsupportedVersion, h.Features&^KnownFeatures)
+	}
+	return nil
+}
+
+// UsesChain tells whether records of a segment with the given features are
+// fed into the record hash chain. Signed segments use the chain as their
+// content digest even without FeatureHashChain.
+func UsesChain(features uint64) bool {
+	return features&(FeatureHashChain|FeatureSignature) != 0
+}
+
+// ExtensionSize returns the size of the extension for the given features.
+func ExtensionSize(features uint64) int {
+	var n int
+	if features&FeatureHashChain != 0 {
+		n += chainExtensionSize
+	}
+	if features&FeatureSignature != 0 {
+		n += signatureExtensionSize
+	}
+	if features&FeatureEncryption != 0 {
+		n += encryptionExtensionSize
+	}
+	return n
+}
+
+// AppendExtension appends the extension blocks of the given features.
+func AppendExtension(b []byte, features uint64, ext *Extension) []byte {
+	if features&FeatureHashChain != 0 {
+		b = append(b, ext.PrevChainHash[:]...)
+		b = append(b, ext.FinalChainHash[:]...)
+	}
+	if features&FeatureSignature != 0 {
+		b = append(b, ext.ContentDigest[:]...)
+		b = append(b, ext.SignerKey[:]...)
+		b = append(b, ext.Signature[:]...)
+	}
+	if features&FeatureEncryption != 0 {
+		b = append(b, ext.RecordKeyID[:]...)
+		b = append(b, ext.RecordKeySalt[:]...)
+	}
+	return b
+}
+
+// ReadExtension reads the extension that follows the header, returning its
+// raw bytes.
+func ReadExtension(r io.Reader, features uint64, ext *Extension) ([]byte, error) {
+	n := ExtensionSize(features)
+	if n == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return nil, &CorruptionError{Offset: HeaderSize, Reason: "end of file in the middle of header extension"}
+	} else if err != nil {
+		return nil, err
+	}
+	b := buf
+	if features&FeatureHashChain != 0 {
+		copy(ext.PrevChainHash[:], b[:ChainHashSize])
+		copy(ext.FinalChainHash[:], b[ChainHashSize:chainExtensionSize])
+		b = b[chainExtensionSize:]
+	}
+	if features&FeatureSignature != 0 {
+		copy(ext.ContentDigest[:], b[:ChainHashSize])
+		copy(ext.SignerKey[:], b[ChainHashSize:ChainHashSize+ed25519.PublicKeySize])
+		copy(ext.Signature[:], b[ChainHashSize+ed25519.PublicKeySize:signatureExtensionSize])
+		b = b[signatureExtensionSize:]
+	}
+	if features&FeatureEncryption != 0 {
+		copy(ext.RecordKeyID[:], b[:sealer.IDSize])
+		copy(ext.RecordKeySalt[:], b[sealer.IDSize:encryptionExtensionSize])
+	}
+	return buf, nil
+}
+
+// AppendRecordHeader appends the size and timestamp prefix of a record of
+// an unsealed file.
+func AppendRecordHeader(b []byte, size int, tsDelta uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(size)<<recordFlagShift)
+	b = binary.AppendUvarint(b, uint64(tsDelta))
+	return b
+}
+
+// AppendSealedRecordHeader appends the size and timestamp prefix of a record
+// of a sealed file.
+func AppendSealedRecordHeader(b []byte, size int, tsDelta uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(size))
+	b = binary.AppendUvarint(b, uint64(tsDelta))
+	return b
+}
+
+// CommitChecksum returns the commit item value for the running checksum of
+// all preceding items.
+func CommitChecksum(dataHash *xxhash.Digest) uint64 {
+	return dataHash.Sum64() | uint64(RecordFlagCommit)
+}
+
+// ChainRecord computes the hash chain value of a record given the value of
+// the previous record (or the final value of the previous segment).
+func ChainRecord(h hash.Hash, prev *[ChainHashSize]byte, id, ts uint64, data []byte) {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[0:], id)
+	binary.LittleEndian.PutUint64(buf[8:], ts)
+	h.Reset()
+	h.Write(prev[:])
+	h.Write(buf[:])
+	h.Write(data)
+	h.Sum(prev[:0])
+}
+
+// SignedMessage returns the message signed by FeatureSignature. It omits
+// the magic, the header checksum and FeatureEncryption, so that sealing
+// a signed segment keeps its signature valid.
+func SignedMessage(h *Header, ext *Extension) []byte {
+	b := make([]byte, 0, len(signatureDomain)+6*8+3*32)
+	b = append(b, signatureDomain...)
+	b = binary.LittleEndian.AppendUint64(b, h.SegmentNumber)
+	b = binary.LittleEndian.AppendUint64(b, h.FirstTimestamp)
+	b = binary.LittleEndian.AppendUint64(b, h.FirstRecordNumber)
+	b = binary.LittleEndian.AppendUint64(b, h.LastTimestamp)
+	b = binary.LittleEndian.AppendUint64(b, h.LastRecordNumber)
+	b = append(b, h.JournalInvariant[:]...)
+	b = append(b, h.SegmentInvariant[:]...)
+	b = binary.LittleEndian.AppendUint64(b, h.Features&^FeatureEncryption)
+	if h.Features&FeatureHashChain != 0 {
+		b = append(b, ext.PrevChainHash[:]...)
+	}
+	b = append(b, ext.ContentDigest[:]...)
+	return b
+}
@@ -0,0 +1,79 @@
+package segfile
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/andreyvit/sealer"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Records of unsealed segments with FeatureEncryption are stored as
+//
+//   - data = nonce:192 ciphertext
+//
+// where ciphertext is XChaCha20-Poly1305 encryption of the record data
+// under a per-segment key, with segnum:64 recnum:64 timestamp:64 as
+// additional data. Nonces are random, because records discarded during
+// recovery get rewritten under the same record numbers.
+//
+// The per-segment key is HMAC-SHA256(sealKey, recordKeyDomain || salt),
+// where the seal key ID and the random salt are kept in the segment
+// extension. Sealing decrypts the records, so sealed segments never have
+// this feature.
+
+const recordKeyDomain = "journal record key v1\x00"
+
+const recordNonceSize = chacha20poly1305.NonceSizeX
+
+// RecordOverhead is the number of bytes encryption adds to each record.
+const RecordOverhead = recordNonceSize + chacha20poly1305.Overhead
+
+// RecordAEAD derives the per-segment record cipher from the seal key named
+// by Extension.RecordKeyID.
+func RecordAEAD(key *sealer.Key, salt *[32]byte) cipher.AEAD {
+	mac := hmac.New(sha256.New, key.Key[:])
+	mac.Write([]byte(recordKeyDomain))
+	mac.Write(salt[:])
+	aead, err := chacha20poly1305.NewX(mac.Sum(nil))
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+func recordAD(buf *[24]byte, segnum, recnum, ts uint64) []byte {
+	binary.LittleEndian.PutUint64(buf[0:], segnum)
+	binary.LittleEndian.PutUint64(buf[8:], recnum)
+	binary.LittleEndian.PutUint64(buf[16:], ts)
+	return buf[:]
+}
+
+// EncryptRecord encrypts record data into dst using a nonce read from rnd.
+func EncryptRecord(aead cipher.AEAD, rnd io.Reader, dst []byte, segnum, recnum, ts uint64, data []byte) ([]byte, error) {
+	dst = slices.Grow(dst[:0], RecordOverhead+len(data))[:recordNonceSize]
+	if _, err := io.ReadFull(rnd, dst); err != nil {
+		return nil, fmt.Errorf("generating record nonce: %w", err)
+	}
+	var ad [24]byte
+	return aead.Seal(dst, dst, data, recordAD(&ad, segnum, recnum, ts)), nil
+}
+
+// DecryptRecord decrypts record data into dst, returning false if the data
+// has been tampered with or belongs to a different record.
+func DecryptRecord(aead cipher.AEAD, dst []byte, segnum, recnum, ts uint64, data []byte) ([]byte, bool) {
+	if len(data) < RecordOverhead {
+		return nil, false
+	}
+	var ad [24]byte
+	plain, err := aead.Open(dst[:0], data[:recordNonceSize], data[recordNonceSize:], recordAD(&ad, segnum, recnum, ts))
+	if err != nil {
+		return nil, false
+	}
+	return plain, true
+}
@@ -0,0 +1,123 @@
+package segfile_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andreyvit/journal"
+	"github.com/andreyvit/journal/segfile"
+	"github.com/andreyvit/sealer"
+)
+
+var sealKey = &sealer.Key{
+	ID:  [32]byte{'X'},
+	Key: [32]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32},
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	j := journal.New(dir, journal.Options{
+		FileName: "j*.wal",
+		SealKeys: []*sealer.Key{sealKey},
+		Now:      func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) },
+	})
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.WriteRecord(0, []byte("two")))
+	ensure(j.Commit())
+	ensure(j.WriteRecord(0, []byte("three")))
+	ensure(j.FinishWriting())
+
+	draft := filepath.Join(dir, "jW0000000001-20240101T000000000-000000000001.wal")
+	f := must(segfile.Open(draft, nil))
+	eq(t, f.Header.Magic, segfile.MagicDraft)
+	eq(t, f.Header.SegmentNumber, 1)
+	eq(t, f.Header.FirstRecordNumber, 1)
+	eq(t, f.Header.Features, 0)
+	eq(t, records(t, f), "1@128:one 2@133:two 3@146:three")
+	f.Close()
+
+	// a damaged record is reported at the commit that covers it
+	b := must(os.ReadFile(draft))
+	b[135] ^= 0xFF
+	ensure(os.WriteFile(draft, b, 0o644))
+	f = must(segfile.Open(draft, nil))
+	var err error
+	for err == nil {
+		err = f.Next()
+	}
+	f.Close()
+	var ce *segfile.CorruptionError
+	if !errors.As(err, &ce) || !errors.Is(err, segfile.ErrCorrupted) {
+		t.Fatalf("got %v, wanted CorruptionError", err)
+	}
+	eq(t, ce.Offset, 138)
+	eq(t, f.CommittedID, 0)
+
+	// a damaged header
+	b[8] ^= 0xFF
+	ensure(os.WriteFile(draft, b, 0o644))
+	_, err = segfile.Open(draft, nil)
+	if !errors.As(err, &ce) {
+		t.Fatalf("got %v, wanted CorruptionError", err)
+	}
+	eq(t, ce.Offset, 120)
+	b[8] ^= 0xFF
+	b[135] ^= 0xFF
+	ensure(os.WriteFile(draft, b, 0o644))
+
+	ensure(j.Rotate())
+	must(j.Seal(context.Background()))
+	sealed := filepath.Join(dir, "jS0000000001-20240101T000000000-000000000001.wal")
+	_, err = segfile.Open(sealed, nil)
+	if !errors.Is(err, segfile.ErrMissingKey) {
+		t.Fatalf("got %v, wanted ErrMissingKey", err)
+	}
+	f = must(segfile.Open(sealed, []*sealer.Key{sealKey}))
+	defer f.Close()
+	eq(t, f.Header.Magic, segfile.MagicSealed)
+	eq(t, f.Header.LastRecordNumber, 3)
+	eq(t, f.KeyID, sealKey.ID)
+	eq(t, records(t, f), "1@0:one 2@5:two 3@10:three")
+}
+
+func records(t testing.TB, f *segfile.File) string {
+	var s string
+	for {
+		err := f.Next()
+		if err == io.EOF {
+			return s
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if s != "" {
+			s += " "
+		}
+		s += fmt.Sprintf("%d@%d:%s", f.ID, f.Offset, f.Data)
+	}
+}
+
+func ensure(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func eq[T comparable](t testing.TB, a, e T) {
+	t.Helper()
+	if a != e {
+		t.Fatalf("got %v, wanted %v", a, e)
+	}
+}
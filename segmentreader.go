@@ -2,33 +2,31 @@ package journal
 
 import (
 	"bufio"
-	"encoding/binary"
+	"crypto/cipher"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/andreyvit/journal/segfile"
 	"github.com/andreyvit/sealer"
-	"github.com/cespare/xxhash/v2"
 )
 
 type segmentReader struct {
-	j             *Journal
-	f             *os.File
-	r             *bufio.Reader
-	dataHash      xxhash.Digest
-	h             segmentHeader
-	hbuf          [segmentHeaderSize]byte
-	seg           Segment
-	rec           uint64
-	ts            uint64
-	size          int64
-	recordsInSeg  int
-	committedRec  uint64
-	committedTS   uint64
-	committedSize int64
-	lastTS        uint64
-	lastRec       uint64
-	data          []byte
+	*segfile.Decoder
+	j      *Journal
+	f      io.Reader
+	r      *bufio.Reader
+	h      segfile.Header
+	hbuf   [segfile.HeaderSize]byte
+	ext    segfile.Extension
+	extbuf []byte
+	keyID  [sealer.IDSize]byte
+	seg    Segment
+
+	verifyDigest bool
+	tailAsEOF    bool        // committed-only read of a draft
+	aead         cipher.AEAD // non-nil for FeatureEncryption
 }
 
 func verifySegment(j *Journal, f *os.File, seg Segment) (*segmentReader, error) {
@@ -48,6 +46,13 @@ func verifySegment(j *Journal, f *os.File, seg Segment) (*segmentReader, error)
 }
 
 func openSegment(j *Journal, seg Segment) (*os.File, *segmentReader, error) {
+	return openSegmentAt(j, seg, nil)
+}
+
+// openSegmentAt is openSegment that continues after the record described by
+// st, which must be in an unsealed segment. Returns ErrInvalidPosition if
+// st.End is out of range.
+func openSegmentAt(j *Journal, seg Segment, st *segfile.ResumeState) (*os.File, *segmentReader, error) {
 	f, err := j.openFile(seg, false)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -59,35 +64,79 @@ func openSegment(j *Journal, seg Segment) (*os.File, *segmentReader, error) {
 	var ok bool
 	defer closeUnlessOK(f, &ok)
 
-	sr, err := newSegmentReader(j, f, seg)
+	sr, err := readSegmentAt(j, f, seg, st)
 	if err != nil {
 		return nil, nil, err
 	}
+	ok = true
+	return f, sr, nil
+}
+
+// readSegmentAt is openSegmentAt for an already open file.
+func readSegmentAt(j *Journal, f io.ReadSeeker, seg Segment, st *segfile.ResumeState) (*segmentReader, error) {
+	sr, err := newSegmentReader(j, f, seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(j.verifyKeys) > 0 && !seg.status.IsDraft() {
+		err := j.verifySignature(sr)
+		if err != nil {
+			return nil, err
+		}
+		sr.verifyDigest = true
+	}
+
+	if st != nil {
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if st.End < sr.Offset || st.End > size {
+			return nil, ErrInvalidPosition
+		}
+		if _, err := f.Seek(st.End, io.SeekStart); err != nil {
+			return nil, err
+		}
+		sr.r.Reset(f)
+		sr.Decoder = segfile.ResumeDecoder(sr.r, &sr.h, st, sr.aead)
+	}
+
+	if !seg.status.IsSealed() {
+		// never surface records that recovery could roll back
+		sr.SetCommittedOnly()
+		sr.tailAsEOF = seg.status.IsDraft()
+	}
 
 	if seg.status.IsSealed() {
-		opn, err := sealer.Prepare(sr.r, sr.hbuf[:])
+		opn, err := sealer.Prepare(sr.r, sr.prefix())
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		key := j.findKey(opn.KeyID)
 		if key == nil {
-			return nil, nil, ErrMissingSealKey
+			key, err = j.dataKey(opn.KeyID)
+			if err != nil {
+				return nil, err
+			} else if key == nil {
+				return nil, ErrMissingSealKey
+			}
 		}
+		sr.keyID = opn.KeyID
 
 		r, err := opn.Open(key)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		sr.r = bufio.NewReader(r)
+		sr.Decoder = segfile.NewDecoder(sr.r, &sr.h, &sr.ext, 0, nil)
 	}
-
-	ok = true
-	return f, sr, nil
+	return sr, nil
 }
 
-func loadSegmentHeader(j *Journal, h *segmentHeader, seg Segment) error {
+func loadSegmentHeader(j *Journal, h *segfile.Header, ext *segfile.Extension, seg Segment) error {
 	f, err := j.openFile(seg, false)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -97,196 +146,126 @@ func loadSegmentHeader(j *Journal, h *segmentHeader, seg Segment) error {
 	}
 	defer f.Close()
 
-	var hbuf [segmentHeaderSize]byte
-	return readSegmentHeader(j, f, h, seg, &hbuf)
+	var hbuf [segfile.HeaderSize]byte
+	err = readSegmentHeader(j, f, h, seg, &hbuf)
+	if err != nil {
+		return err
+	}
+	_, err = segfile.ReadExtension(f, h.Features, ext)
+	return err
 }
 
-func newSegmentReader(j *Journal, f *os.File, seg Segment) (*segmentReader, error) {
+func newSegmentReader(j *Journal, f io.Reader, seg Segment) (*segmentReader, error) {
 	sr := &segmentReader{
-		j:             j,
-		f:             f,
-		r:             bufio.NewReader(f),
-		seg:           seg,
-		rec:           seg.recnum - 1,
-		ts:            seg.ts,
-		size:          0,
-		committedRec:  0,
-		committedTS:   0,
-		committedSize: 0,
+		j:   j,
+		f:   f,
+		r:   bufio.NewReader(f),
+		seg: seg,
 	}
-	sr.dataHash.Reset()
 
-	err := readSegmentHeader(j, f, &sr.h, seg, &sr.hbuf)
+	err := readSegmentHeader(j, sr.r, &sr.h, seg, &sr.hbuf)
 	if err != nil {
-		return sr, err
+		return nil, err
 	}
-	sr.size = int64(segmentHeaderSize)
-	sr.committedSize = int64(segmentHeaderSize)
+	sr.extbuf, err = segfile.ReadExtension(sr.r, sr.h.Features, &sr.ext)
+	if err != nil {
+		return nil, err
+	}
+	if sr.h.Features&segfile.FeatureEncryption != 0 {
+		if seg.status.IsSealed() {
+			j.logger.Warn("journal corrupted header: record encryption in a sealed file", "journal", j.debugName, "segment", seg.String())
+			return nil, errCorruptedFile
+		}
+		sr.aead, err = j.openRecordAEAD(&sr.ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sr.Decoder = segfile.NewDecoder(sr.r, &sr.h, &sr.ext, int64(segfile.HeaderSize+len(sr.extbuf)), sr.aead)
 	return sr, nil
 }
 
+// prefix returns the raw header and extension bytes, which sealed segments
+// authenticate as the sealer's outer prefix.
+func (sr *segmentReader) prefix() []byte {
+	if len(sr.extbuf) == 0 {
+		return sr.hbuf[:]
+	}
+	return append(sr.hbuf[:len(sr.hbuf):len(sr.hbuf)], sr.extbuf...)
+}
+
 func (sr *segmentReader) next() error {
-	isUnsealed := !sr.seg.status.IsSealed()
-	for {
-		b, err := sr.r.Peek(maxRecHeaderLen)
-		if err == io.EOF {
-			if len(b) == 0 {
-				// end of file; was there a commit?
-				if !isUnsealed || sr.size == sr.committedSize {
-					return io.EOF
-				} else {
-					if sr.j.verbose {
-						sr.j.logger.Debug("journal corrupted record: end of file without a commit", "journal", sr.j.debugName)
-					}
-					return errCorruptedFile
-				}
-			}
-		} else if err != nil {
+	err := sr.Next()
+	if err == io.EOF {
+		if sr.verifyDigest && sr.Chain != sr.ext.ContentDigest {
+			sr.j.logger.Warn("journal segment content does not match its signature", "journal", sr.j.debugName, "segment", sr.seg.String())
+			return &SignatureError{Segment: sr.seg, Reason: "content digest mismatch"}
+		}
+		return io.EOF
+	} else if err != nil {
+		var ce *segfile.CorruptionError
+		if !errors.As(err, &ce) {
 			return err
 		}
-		if isUnsealed && (b[0]&recordFlagCommit != 0) {
-			var b [8]byte
-			_, err := io.ReadFull(sr.r, b[:])
-			if err == io.ErrUnexpectedEOF {
-				if sr.j.verbose {
-					sr.j.logger.Debug("journal corrupted record: end of file in the middle of commit", "journal", sr.j.debugName)
-				}
-				return errCorruptedFile
-			} else if err != nil {
-				return err
-			}
-			actual := binary.LittleEndian.Uint64(b[:])
-			expected := sr.dataHash.Sum64() | uint64(recordFlagCommit)
-			sr.dataHash.Write(b[:])
-			if actual != expected {
-				sr.j.logger.Warn("journal corrupted record: commit checksum mismatch", "journal", sr.j.debugName, "actual", fmt.Sprintf("%08x", actual), "expected", fmt.Sprintf("%08x", expected))
-				return errCorruptedFile
-			}
-
-			sr.size += 8
-			if sr.recordsInSeg > 0 {
-				sr.committedRec = sr.rec
-				sr.committedTS = sr.ts
-				sr.committedSize = sr.size
-
-				if sr.j.veryVerbose {
-					sr.j.logger.Debug("journal commit decoded", "journal", sr.j.debugName)
-				}
-			} else {
-				if sr.j.verbose {
-					sr.j.logger.Debug("journal corrupted record: commit without a prior record", "journal", sr.j.debugName)
-				}
-				return errCorruptedFile
-			}
-		} else {
-			rawSize, n1 := binary.Uvarint(b)
-			if n1 <= 0 {
-				if sr.j.verbose {
-					sr.j.logger.Debug("journal corrupted record: cannot decode size", "journal", sr.j.debugName)
-				}
-				return errCorruptedFile
-			}
-			var dataSize int
-			if isUnsealed {
-				dataSize = int(rawSize / 2)
-			} else {
-				dataSize = int(rawSize)
-			}
-
-			tsdelta, n2 := binary.Uvarint(b[n1:])
-			if n2 <= 0 {
-				if sr.j.verbose {
-					sr.j.logger.Debug("journal corrupted record: cannot decode timestamp", "journal", sr.j.debugName)
-				}
-				return errCorruptedFile
-			}
-
-			// if sr.j.verbose {
-			// 	sr.j.logger.Debug("hash before record", "journal", sr.j.debugName, "hash", fmt.Sprintf("%08x", sr.hash.Sum64()))
-			// }
-
-			n := n1 + n2
-			if isUnsealed {
-				sr.dataHash.Write(b[:n])
-			}
-			sr.r.Discard(n)
-
-			if cap(sr.data) < dataSize {
-				sr.data = make([]byte, dataSize, allocSize(dataSize))
-			} else {
-				sr.data = sr.data[:dataSize]
-			}
-
-			_, err = io.ReadFull(sr.r, sr.data)
-			if err == io.ErrUnexpectedEOF {
-				if sr.j.verbose {
-					sr.j.logger.Debug("journal corrupted record: EOF when reading record data", "journal", sr.j.debugName, "offset", fmt.Sprintf("%08x", sr.size+int64(n)), "size", dataSize)
-				}
-				return errCorruptedFile
-			} else if err != nil {
-				return err
+		if sr.tailAsEOF && sr.isTail(ce) {
+			// an in-progress write, or an uncommitted tail to be discarded
+			// by recovery
+			if sr.j.verbose {
+				sr.j.logger.Debug("journal draft read stopped at uncommitted tail", "journal", sr.j.debugName, "segment", sr.seg.String(), "offset", fmt.Sprintf("%08x", ce.Offset), "reason", ce.Reason)
 			}
-
-			sr.recordsInSeg++
-			sr.rec++
-			sr.ts += tsdelta
-			sr.size += int64(n + dataSize)
-
-			if isUnsealed {
-				sr.dataHash.Write(sr.data)
-			} else {
-				sr.committedRec = sr.rec
-				sr.committedTS = sr.ts
-				sr.committedSize = sr.size
-			}
-
-			if sr.j.veryVerbose {
-				sr.j.logger.Debug("journal record decoded", "journal", sr.j.debugName, "data", string(sr.data), "hash", fmt.Sprintf("%08x", sr.dataHash.Sum64()))
-			}
-
-			return nil
+			return io.EOF
+		}
+		if sr.j.verbose {
+			sr.j.logger.Debug("journal corrupted record", "journal", sr.j.debugName, "segment", sr.seg.String(), "offset", fmt.Sprintf("%08x", ce.Offset), "reason", ce.Reason)
 		}
+		return err
+	}
+	if sr.j.veryVerbose {
+		sr.j.logger.Debug("journal record decoded", "journal", sr.j.debugName, "data", string(sr.Data), "offset", fmt.Sprintf("%08x", sr.Offset))
+	}
+	return nil
+}
+
+// isTail tells whether a corruption of a draft can be an in-progress write
+// or an uncommitted tail: the file ends in the middle of an item, or the
+// corruption follows all the records known to be committed. Anything else
+// damages committed records.
+func (sr *segmentReader) isTail(ce *segfile.CorruptionError) bool {
+	if ce.Truncated {
+		return true
 	}
+	last, known := sr.j.lastCommittedID()
+	return known && sr.CommittedID >= last
 }
 
-func readSegmentHeader(j *Journal, r io.Reader, h *segmentHeader, seg Segment, buf *[segmentHeaderSize]byte) error {
+func readSegmentHeader(j *Journal, r io.Reader, h *segfile.Header, seg Segment, buf *[segfile.HeaderSize]byte) error {
 	_, err := io.ReadFull(r, buf[:])
 	if err == io.ErrUnexpectedEOF || err == io.EOF {
 		return errCorruptedFile
 	} else if err != nil {
 		return err
 	}
-	n, err := binary.Decode(buf[:], binary.LittleEndian, h)
-	if err != nil {
-		panic(err)
-	}
-	if n != len(buf) {
-		panic("internal size mismatch")
-	}
-
-	var hash xxhash.Digest
-	hash.Reset()
-	hash.Write(buf[:segmentHeaderSize-8])
-	checksum := hash.Sum64()
+	*h = segfile.DecodeHeader(buf)
+	checksum := segfile.HeaderChecksum(buf)
 
-	if h.Magic != magicV1Draft && h.Magic != magicV1Sealed && h.Magic != magicV1Finalized {
+	if h.Magic != segfile.MagicDraft && h.Magic != segfile.MagicSealed && h.Magic != segfile.MagicFinalized {
 		j.logger.Warn("journal incompatible header: version", "journal", j.debugName)
 		return ErrUnsupportedVersion
 	}
 	if seg.status.IsSealed() {
-		if h.Magic != magicV1Sealed {
+		if h.Magic != segfile.MagicSealed {
 			j.logger.Warn("journal wrong header magic: unsealed format in a sealed file", "journal", j.debugName)
 			return errCorruptedFile
 		}
 	} else if seg.status.IsDraft() {
 		// allow finalized magic because we could have crashed while updating
 		// the magic
-		if h.Magic != magicV1Draft && h.Magic != magicV1Finalized {
+		if h.Magic != segfile.MagicDraft && h.Magic != segfile.MagicFinalized {
 			j.logger.Warn("journal wrong header magic: sealed format in a draft file", "journal", j.debugName)
 			return errCorruptedFile
 		}
 	} else if seg.status == Finalized {
-		if h.Magic != magicV1Finalized {
+		if h.Magic != segfile.MagicFinalized {
 			j.logger.Warn("wrong header magic: non-finalized format in a finalized file", "journal", j.debugName)
 			return errCorruptedFile
 		}
@@ -295,6 +274,10 @@ func readSegmentHeader(j *Journal, r io.Reader, h *segmentHeader, seg Segment, b
 		j.logger.Warn("journal corrupted header: checksum", "journal", j.debugName, "actual", fmt.Sprintf("%08x", h.HeaderChecksum), "expected", fmt.Sprintf("%08x", checksum))
 		return errCorruptedFile
 	}
+	if h.Features&^segfile.KnownFeatures != 0 {
+		j.logger.Warn("journal incompatible header: unknown features", "journal", j.debugName, "features", fmt.Sprintf("%x", h.Features))
+		return ErrUnsupportedVersion
+	}
 	if seg.segnum != h.SegmentNumber {
 		j.logger.Warn("journal corrupted header: segment ordinal", "journal", j.debugName)
 		return errCorruptedFile
@@ -311,6 +294,10 @@ func readSegmentHeader(j *Journal, r io.Reader, h *segmentHeader, seg Segment, b
 		j.logger.Warn("incompatible header: journal invariant", "journal", j.debugName)
 		return ErrIncompatible
 	}
+	if !j.isSegmentInvariantAccepted(h.SegmentInvariant) {
+		j.logger.Warn("journal incompatible header: segment invariant", "journal", j.debugName, "segment", seg.String())
+		return &SegmentInvariantError{Segment: seg, Invariant: h.SegmentInvariant}
+	}
 
 	return nil
 }
@@ -119,6 +119,9 @@ func (j *Journal) enumSegments(f func(Segment) error) error {
 				continue
 			}
 			name := ent.Name()
+			if strings.HasPrefix(name, ".") && !strings.HasPrefix(j.fileNamePrefix, ".") {
+				continue // auxiliary files, see metaFilePath
+			}
 			if !strings.HasPrefix(name, j.fileNamePrefix) || !strings.HasSuffix(name, j.fileNameSuffix) {
 				continue
 			}
@@ -1,12 +1,17 @@
 package journal
 
 import (
+	"crypto/cipher"
+	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log/slog"
 	"os"
 
+	"github.com/andreyvit/journal/segfile"
 	"github.com/cespare/xxhash/v2"
 )
 
@@ -20,11 +25,18 @@ type segmentWriter struct {
 	dataHash    xxhash.Digest
 	uncommitted bool
 	modified    bool
+	invariant   [32]byte
+	features    uint64
+	ext         segfile.Extension
+	chain       [segfile.ChainHashSize]byte
+	chainHasher hash.Hash
+	aead        cipher.AEAD
+	encBuf      []byte
 
 	firstUncommittedWriteTS uint64
 }
 
-func startSegment(j *Journal, segnum, ts, rec uint64) (*segmentWriter, error) {
+func startSegment(j *Journal, segnum, ts, rec uint64, prevChain [segfile.ChainHashSize]byte) (*segmentWriter, error) {
 	seg := Segment{
 		ts:     ts,
 		recnum: rec,
@@ -41,29 +53,64 @@ func startSegment(j *Journal, segnum, ts, rec uint64) (*segmentWriter, error) {
 	defer closeAndDeleteUnlessOK(f, &ok)
 
 	sw := &segmentWriter{
-		j:        j,
-		f:        f,
-		seg:      seg,
-		ts:       ts,
-		nextRec:  rec,
-		size:     segmentHeaderSize,
-		modified: true,
+		j:         j,
+		f:         f,
+		seg:       seg,
+		ts:        ts,
+		nextRec:   rec,
+		size:      segfile.HeaderSize,
+		modified:  true,
+		invariant: j.segmentInvariant,
 	}
 	sw.dataHash.Reset()
+	if j.hashChain {
+		sw.features |= segfile.FeatureHashChain
+		sw.ext.PrevChainHash = prevChain
+		sw.chain = prevChain
+	}
+	if j.signingKey != nil {
+		sw.features |= segfile.FeatureSignature
+	}
+	if segfile.UsesChain(sw.features) {
+		sw.chainHasher = sha256.New()
+	}
+	if j.encryptRecords {
+		sw.features |= segfile.FeatureEncryption
+		sw.aead, err = j.newRecordAEAD(&sw.ext)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	var hbuf [segmentHeaderSize]byte
-	fillSegmentHeader(hbuf[:], j, magicV1Draft, segnum, ts, rec, 0, 0)
+	var hbuf [segfile.HeaderSize]byte
+	fillSegmentHeader(&hbuf, j, segfile.MagicDraft, segnum, ts, rec, 0, 0, sw.invariant, sw.features)
 
-	_, err = f.Write(hbuf[:])
+	_, err = f.Write(segfile.AppendExtension(hbuf[:], sw.features, &sw.ext))
 	if err != nil {
 		return nil, err
 	}
+	sw.size += int64(segfile.ExtensionSize(sw.features))
 
 	ok = true
 	j.updateStateWithSegmentAdded(seg)
 	return sw, nil
 }
 
+// optionFeatures are the segment features that depend on the options. A draft
+// written with other ones is finalized instead of being continued.
+const optionFeatures = segfile.FeatureSignature | segfile.FeatureEncryption
+
+func (j *Journal) optionFeatures() uint64 {
+	var features uint64
+	if j.signingKey != nil {
+		features |= segfile.FeatureSignature
+	}
+	if j.encryptRecords {
+		features |= segfile.FeatureEncryption
+	}
+	return features
+}
+
 func continueSegment(j *Journal, seg Segment) (*segmentWriter, error) {
 	f, err := j.openFile(seg, true)
 	if err != nil {
@@ -78,16 +125,16 @@ func continueSegment(j *Journal, seg Segment) (*segmentWriter, error) {
 
 	sr, err := verifySegment(j, f, seg)
 	var recoveredModified bool
-	if err == errCorruptedFile {
-		if sr == nil || sr.committedRec == 0 {
+	if errors.Is(err, errCorruptedFile) {
+		if sr == nil || sr.CommittedID == 0 {
 			err := j.quarantineSegment(seg, errCorruptedFile)
 			if err != nil {
 				return nil, fmt.Errorf("journal: failed to quarantine corrupted file: %w", err)
 			}
 			return nil, errFileGone
 		} else {
-			j.logger.LogAttrs(j.context, slog.LevelWarn, "journal recovered corrupted file", slog.String("journal", j.debugName), slog.String("segment", seg.String()), slog.Int("record", int(sr.committedRec)))
-			err := f.Truncate(sr.committedSize)
+			j.logger.LogAttrs(j.context, slog.LevelWarn, "journal recovered corrupted file", slog.String("journal", j.debugName), slog.String("segment", seg.String()), slog.Int("record", int(sr.CommittedID)))
+			err := f.Truncate(sr.CommittedSize)
 			if err != nil {
 				return nil, fmt.Errorf("journal failed to truncate corrupted file: %w", err)
 			}
@@ -102,28 +149,39 @@ func continueSegment(j *Journal, seg Segment) (*segmentWriter, error) {
 			sr.j.logger.Info("journal segment recovered", "journal", sr.j.debugName, "segment", seg.String())
 
 			sr, err = verifySegment(j, f, seg)
-			if err == errCorruptedFile {
+			if errors.Is(err, errCorruptedFile) {
 				return nil, fmt.Errorf("journal failured to recover corrupted file")
 			} else if err != nil {
 				return nil, err
 			}
-			if sr.size != sr.committedSize {
+			if sr.Size != sr.CommittedSize {
 				panic("journal unreachable internal error")
 			}
 		}
+	} else if err != nil {
+		return nil, err
 	}
 
 	ok = true
-	return &segmentWriter{
-		j:        j,
-		f:        f,
-		seg:      sr.seg,
-		ts:       sr.ts,
-		nextRec:  sr.rec + 1,
-		size:     sr.committedSize,
-		dataHash: sr.dataHash,
-		modified: recoveredModified,
-	}, nil
+	sw := &segmentWriter{
+		j:         j,
+		f:         f,
+		seg:       sr.seg,
+		ts:        sr.Timestamp,
+		nextRec:   sr.ID + 1,
+		size:      sr.CommittedSize,
+		dataHash:  sr.DataHash(),
+		modified:  recoveredModified,
+		invariant: sr.h.SegmentInvariant,
+		features:  sr.h.Features,
+		ext:       sr.ext,
+		chain:     sr.Chain,
+		aead:      sr.aead,
+	}
+	if segfile.UsesChain(sw.features) {
+		sw.chainHasher = sha256.New()
+	}
+	return sw, nil
 }
 
 func (sw *segmentWriter) lastMeta() Meta {
@@ -137,8 +195,18 @@ func (sw *segmentWriter) writeRecord(ts uint64, data []byte) error {
 		sw.ts = ts
 	}
 
-	var hbuf [maxRecHeaderLen]byte
-	h := appendRecordHeader(hbuf[:0], len(data), tsDelta)
+	plain := data
+	if sw.aead != nil {
+		var err error
+		data, err = segfile.EncryptRecord(sw.aead, sw.j.randomReader(), sw.encBuf, sw.seg.segnum, sw.nextRec, sw.ts, data)
+		if err != nil {
+			return err
+		}
+		sw.encBuf = data
+	}
+
+	var hbuf [segfile.MaxRecordHeaderSize]byte
+	h := segfile.AppendRecordHeader(hbuf[:0], len(data), tsDelta)
 
 	// if sw.j.verbose {
 	// 	sw.j.logger.Debug("hash before record", "journal", sw.j.debugName, "record", string(data), "hash", fmt.Sprintf("%08x", sw.hash.Sum64()))
@@ -156,6 +224,10 @@ func (sw *segmentWriter) writeRecord(ts uint64, data []byte) error {
 		return err
 	}
 
+	if sw.chainHasher != nil {
+		segfile.ChainRecord(sw.chainHasher, &sw.chain, sw.nextRec, sw.ts, plain)
+	}
+
 	sw.uncommitted = true
 	sw.modified = true
 	sw.nextRec++
@@ -177,7 +249,7 @@ func (sw *segmentWriter) commit() error {
 	sw.size += 8
 
 	var buf [8]byte
-	binary.LittleEndian.PutUint64(buf[:], sw.dataHash.Sum64()|uint64(recordFlagCommit))
+	binary.LittleEndian.PutUint64(buf[:], segfile.CommitChecksum(&sw.dataHash))
 
 	sw.dataHash.Write(buf[:])
 	_, err := sw.f.Write(buf[:])
@@ -213,15 +285,20 @@ func (sw *segmentWriter) close(mode closeMode) error {
 		}
 
 		if mode.shouldFinalize() && sw.seg.status == Draft {
-			var hbuf [segmentHeaderSize]byte
-			fillSegmentHeader(hbuf[:], sw.j, magicV1Finalized, sw.seg.segnum, sw.seg.ts, sw.seg.recnum, sw.ts, sw.nextRec-1)
+			var hbuf [segfile.HeaderSize]byte
+			h := fillSegmentHeader(&hbuf, sw.j, segfile.MagicFinalized, sw.seg.segnum, sw.seg.ts, sw.seg.recnum, sw.ts, sw.nextRec-1, sw.invariant, sw.features)
+			sw.ext.FinalChainHash = sw.finalChain()
+			if sw.features&segfile.FeatureSignature != 0 {
+				sw.ext.ContentDigest = sw.chain
+				sw.j.signSegment(&h, &sw.ext)
+			}
 
 			_, err = sw.f.Seek(0, io.SeekStart)
 			if err != nil {
 				return err
 			}
 
-			_, err = sw.f.Write(hbuf[:])
+			_, err = sw.f.Write(segfile.AppendExtension(hbuf[:], sw.features, &sw.ext))
 			if err != nil {
 				return err
 			}
@@ -250,6 +327,14 @@ func (sw *segmentWriter) close(mode closeMode) error {
 	return nil
 }
 
+// finalChain returns the hash chain value to be recorded by the next segment.
+func (sw *segmentWriter) finalChain() [segfile.ChainHashSize]byte {
+	if sw.features&segfile.FeatureHashChain == 0 {
+		return [segfile.ChainHashSize]byte{}
+	}
+	return sw.chain
+}
+
 func (sw *segmentWriter) checksum() uint64 {
 	return sw.dataHash.Sum64()
 }
@@ -0,0 +1,69 @@
+package journal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/andreyvit/journal/segfile"
+)
+
+var ErrInvalidSignature = errors.New("invalid journal segment signature")
+
+// SignatureError is returned when Options.VerifyKeys is set and a finalized
+// or sealed segment is unsigned, signed by an unknown key, or its signature
+// does not match its header or contents.
+type SignatureError struct {
+	Segment Segment
+	Reason  string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("journal segment %v: %v: %s", e.Segment, ErrInvalidSignature, e.Reason)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return ErrInvalidSignature
+}
+
+// signSegment fills in the signature of a segment being finalized. A draft
+// started with a signing key that is no longer available keeps an empty
+// signature, because the extension layout of the file cannot change.
+func (j *Journal) signSegment(h *segfile.Header, ext *segfile.Extension) {
+	if j.signingKey == nil {
+		j.logger.Warn("journal finalizing a signed segment without a signing key", "journal", j.debugName, "segment", h.SegmentNumber)
+		return
+	}
+	copy(ext.SignerKey[:], j.signingKey.Public().(ed25519.PublicKey))
+	copy(ext.Signature[:], ed25519.Sign(j.signingKey, segfile.SignedMessage(h, ext)))
+}
+
+// verifySignature checks the header signature of a segment. Contents are
+// checked against the signed digest when the reader reaches the end.
+func (j *Journal) verifySignature(sr *segmentReader) error {
+	if sr.h.Features&segfile.FeatureSignature == 0 {
+		j.logger.Warn("journal segment is not signed", "journal", j.debugName, "segment", sr.seg.String())
+		return &SignatureError{Segment: sr.seg, Reason: "segment is not signed"}
+	}
+	if sr.ext.SignerKey == [ed25519.PublicKeySize]byte{} {
+		j.logger.Warn("journal segment finalized without a signing key", "journal", j.debugName, "segment", sr.seg.String())
+		return &SignatureError{Segment: sr.seg, Reason: "segment was finalized without a signing key"}
+	}
+	var key ed25519.PublicKey
+	for _, k := range j.verifyKeys {
+		if bytes.Equal(k, sr.ext.SignerKey[:]) {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		j.logger.Warn("journal segment signed by unknown key", "journal", j.debugName, "segment", sr.seg.String(), "key", fmt.Sprintf("%x", sr.ext.SignerKey))
+		return &SignatureError{Segment: sr.seg, Reason: "unknown signer key"}
+	}
+	if !ed25519.Verify(key, segfile.SignedMessage(&sr.h, &sr.ext), sr.ext.Signature[:]) {
+		j.logger.Warn("journal segment signature mismatch", "journal", j.debugName, "segment", sr.seg.String())
+		return &SignatureError{Segment: sr.seg, Reason: "header signature mismatch"}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package journal_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/journal"
+	"github.com/andreyvit/sealer"
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestJournalSignatures(t *testing.T) {
+	seed := func(b byte) ed25519.PrivateKey {
+		var s [ed25519.SeedSize]byte
+		s[0] = b
+		return ed25519.NewKeyFromSeed(s[:])
+	}
+	keyA, keyB := seed('A'), seed('B')
+	pubA, pubB := keyA.Public().(ed25519.PublicKey), keyB.Public().(ed25519.PublicKey)
+
+	j := setupWritable(t, newClock(), journal.Options{
+		MaxFileSize: 165 + 128, // room for the signature extension
+		SigningKey:  keyA,
+		VerifyKeys:  []ed25519.PublicKey{pubA},
+	})
+	writeSeq(j)
+	recsEq(t, j.All(journal.Filter{}), 1,
+		"20240101T000000000:one",
+		"20240101T000001000:two",
+		"20240101T000002000:three",
+		"20240101T000012000:four",
+		"20240101T000022000:five",
+		"20240101T000202000:six",
+		"20240101T000342000:seven",
+		"20240101T010342000:eight",
+		"20240101T020342000:nine",
+		"20240101T030342000:ten")
+
+	must(j.SealAndTrimAll(context.Background()))
+	eq(t, len(j.All(journal.Filter{})), 10)
+
+	readErr := func(o journal.Options) error {
+		o.FileName = "j*.wal"
+		o.SealKeys = []*sealer.Key{sealKey}
+		o.Logger = testLogger(t)
+		var err error
+		for range journal.New(j.Dir, o).Records(journal.Filter{}, func(e error) { err = e }) {
+		}
+		return err
+	}
+	success(t, readErr(journal.Options{VerifyKeys: []ed25519.PublicKey{pubB, pubA}}))
+	success(t, readErr(journal.Options{}))
+
+	var se *journal.SignatureError
+	err := readErr(journal.Options{VerifyKeys: []ed25519.PublicKey{pubB}})
+	ok(t, errors.As(err, &se))
+	ok(t, errors.Is(err, journal.ErrInvalidSignature))
+	eq(t, se.Segment.SegmentNumber(), 1)
+	eq(t, se.Reason, "unknown signer key")
+
+	// forged header with a valid checksum
+	name := "jS0000000002-20240101T000002000-000000000003.wal"
+	path := filepath.Join(j.Dir, name)
+	b := must(os.ReadFile(path))
+	binary.LittleEndian.PutUint64(b[32:], binary.LittleEndian.Uint64(b[32:])+1000) // LastTimestamp
+	binary.LittleEndian.PutUint64(b[120:], xxhash.Sum64(b[:120]))
+	ensure(os.WriteFile(path, b, 0o644))
+	err = readErr(journal.Options{VerifyKeys: []ed25519.PublicKey{pubA}})
+	ok(t, errors.As(err, &se))
+	eq(t, se.Segment.SegmentNumber(), 2)
+	eq(t, se.Reason, "header signature mismatch")
+	_, err = journal.New(j.Dir, journal.Options{
+		FileName:   "j*.wal",
+		SealKeys:   []*sealer.Key{sealKey},
+		VerifyKeys: []ed25519.PublicKey{pubA},
+		Logger:     testLogger(t),
+	}).Count(journal.Filter{})
+	ok(t, errors.As(err, &se))
+	eq(t, se.Reason, "header signature mismatch")
+
+	// unsigned segments are rejected when verifying
+	u := setupWritable(t, newClock(), journal.Options{MaxFileSize: 165})
+	writeSeq(u)
+	ensure(u.Rotate())
+	err = nil
+	for range u.Journal.Records(journal.Filter{}, func(e error) { err = e }) {
+	}
+	success(t, err)
+	v := journal.New(u.Dir, journal.Options{FileName: "j*.wal", VerifyKeys: []ed25519.PublicKey{pubA}, Logger: testLogger(t)})
+	for range v.Records(journal.Filter{}, func(e error) { err = e }) {
+	}
+	ok(t, errors.As(err, &se))
+	eq(t, se.Reason, "segment is not signed")
+}
+
+func TestJournalSignatures_keyChanged(t *testing.T) {
+	var s [ed25519.SeedSize]byte
+	s[0] = 'A'
+	key := ed25519.NewKeyFromSeed(s[:])
+	pub := key.Public().(ed25519.PublicKey)
+	verify := func(dir string, filter journal.Filter) error {
+		var err error
+		k := journal.New(dir, journal.Options{FileName: "j*.wal", VerifyKeys: []ed25519.PublicKey{pub}, Logger: testLogger(t)})
+		for range k.Records(filter, func(e error) { err = e }) {
+		}
+		return err
+	}
+	var se *journal.SignatureError
+	clock := newClock()
+	second := journal.Filter{MinRecordID: 2, MinTimestamp: clock.NowTS()} // skips segment 1
+
+	// unsigned draft, reopened with a key: new records go into a signed segment
+	j := setupWritable(t, clock, journal.Options{})
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.FinishWriting())
+	j2 := open(t, j.clock, j.Dir, journal.Options{SigningKey: key})
+	ensure(j2.WriteRecord(0, []byte("two")))
+	ensure(j2.Rotate())
+	ensure(j2.FinishWriting())
+	deepEq(t, j2.FileNames(), []string{
+		"jF0000000001-20240101T000000000-000000000001.wal",
+		"jF0000000002-20240101T000000000-000000000002.wal",
+	})
+	success(t, verify(j.Dir, second))
+	ok(t, errors.As(verify(j.Dir, journal.Filter{}), &se))
+	eq(t, se.Reason, "segment is not signed")
+
+	// signed draft, reopened without the key: new records go into an unsigned
+	// segment, and the draft doesn't pass for a signed one
+	j = setupWritable(t, clock, journal.Options{SigningKey: key}, nonVerbose)
+	ensure(j.WriteRecord(0, []byte("one")))
+	ensure(j.FinishWriting())
+	j2 = open(t, j.clock, j.Dir, journal.Options{}, nonVerbose)
+	ensure(j2.WriteRecord(0, []byte("two")))
+	ensure(j2.Rotate())
+	ensure(j2.FinishWriting())
+	ok(t, errors.As(verify(j.Dir, journal.Filter{}), &se))
+	eq(t, se.Segment.SegmentNumber(), 1)
+	eq(t, se.Reason, "segment was finalized without a signing key")
+	ok(t, errors.As(verify(j.Dir, second), &se))
+	eq(t, se.Segment.SegmentNumber(), 2)
+	eq(t, se.Reason, "segment is not signed")
+}
@@ -0,0 +1,138 @@
+package journal
+
+import (
+	"io"
+	"os"
+	"slices"
+)
+
+// Snapshot is a stable view of the records committed at the time it was
+// taken. All cursors opened on a snapshot return the same records, no matter
+// what is written, sealed or trimmed in the meantime.
+//
+// The segment files of the snapshot are kept open until Close, so that they
+// stay readable after being finalized, sealed, resealed, quarantined or
+// trimmed. Shredding a sealed segment still makes it unreadable.
+type Snapshot struct {
+	j      *Journal
+	last   Meta
+	segs   []Segment
+	pinned map[uint64]*pinnedFile // by segment number
+}
+
+type pinnedFile struct {
+	f    *os.File
+	size int64
+}
+
+// Snapshot captures the last commit and the current segments of the
+// journal. Close the snapshot to release the open files.
+func (j *Journal) Snapshot() (*Snapshot, error) {
+	for {
+		s, err := j.takeSnapshot()
+		if err == errFileGone {
+			// finalized or trimmed while opening, try again
+			j.resetState()
+			continue
+		}
+		return s, err
+	}
+}
+
+func (j *Journal) takeSnapshot() (*Snapshot, error) {
+	j.state.lock.Lock()
+	if err := j.state.ensureInitialized(j); err != nil {
+		j.state.lock.Unlock()
+		return nil, err
+	}
+	s := &Snapshot{
+		j:      j,
+		last:   j.state.summary().LastCommitted,
+		segs:   j.state.readableSegments(),
+		pinned: make(map[uint64]*pinnedFile),
+	}
+	j.state.lock.Unlock()
+
+	for _, seg := range s.segs {
+		f, err := j.openFile(seg, false)
+		if err != nil {
+			s.Close()
+			if os.IsNotExist(err) {
+				return nil, errFileGone
+			}
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			s.Close()
+			return nil, err
+		}
+		s.pinned[seg.segnum] = &pinnedFile{f, fi.Size()}
+	}
+	return s, nil
+}
+
+// LastCommitted returns the last record of the snapshot.
+func (s *Snapshot) LastCommitted() Meta {
+	return s.last
+}
+
+// Read is Journal.Read over the records of the snapshot.
+func (s *Snapshot) Read(filter Filter) *Cursor {
+	c := s.j.Read(filter)
+	s.attach(c)
+	return c
+}
+
+// ReadReverse is Journal.ReadReverse over the records of the snapshot.
+func (s *Snapshot) ReadReverse(filter Filter) *Cursor {
+	c := s.j.ReadReverse(filter)
+	s.attach(c)
+	return c
+}
+
+func (s *Snapshot) attach(c *Cursor) {
+	c.snap = s
+	if s.last.ID == 0 {
+		c.err = io.EOF
+	} else if c.filter.MaxRecordID == 0 || c.filter.MaxRecordID > s.last.ID {
+		c.filter.MaxRecordID = s.last.ID
+	}
+}
+
+// Close releases the files of the snapshot. Cursors opened on the snapshot
+// must not be used afterwards.
+func (s *Snapshot) Close() error {
+	var firstErr error
+	for _, pf := range s.pinned {
+		if err := pf.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.pinned = nil
+	return firstErr
+}
+
+func (s *Snapshot) findSegments(filter Filter) []Segment {
+	segs, _ := filterSegments(s.segs, filter)
+	return slices.Clone(segs)
+}
+
+// openSegment is openSegment that reads the segments from the files opened
+// by Snapshot. Safe for concurrent use, see Filter.Prefetch.
+func (s *Snapshot) openSegment(seg Segment) (io.Closer, *segmentReader, error) {
+	pf := s.pinned[seg.segnum]
+	if pf == nil {
+		return openSegment(s.j, seg)
+	}
+	sr, err := readSegmentAt(s.j, io.NewSectionReader(pf.f, 0, pf.size), seg, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nopCloser{}, sr, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
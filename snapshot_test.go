@@ -0,0 +1,59 @@
+package journal_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreyvit/journal"
+)
+
+func TestJournalSnapshot(t *testing.T) {
+	j := setupWritable(t, newClock(), journal.Options{MaxFileSize: 165}, nonVerbose)
+	for _, s := range []string{"one", "two", "three"} {
+		ensure(j.WriteRecord(0, []byte(s)))
+		ensure(j.Commit())
+	}
+	ensure(j.WriteRecord(0, []byte("uncommitted")))
+
+	snap := must(j.Snapshot())
+	defer snap.Close()
+	eq(t, snap.LastCommitted().ID, 3)
+	expected := []string{"1:one", "2:two", "3:three"}
+	deepEq(t, collect(t, snap.Read(journal.Filter{})), expected)
+
+	// writes, rotation, sealing and trimming don't affect the snapshot
+	ensure(j.Commit())
+	for _, s := range []string{"five", "six", "seven"} {
+		ensure(j.WriteRecord(0, []byte(s)))
+		ensure(j.Commit())
+	}
+	must(j.SealAndTrimAll(context.Background()))
+	eq(t, len(collect(t, j.Read(journal.Filter{}))), 7)
+
+	deepEq(t, collect(t, snap.Read(journal.Filter{})), expected)
+	deepEq(t, collect(t, snap.Read(journal.Filter{MinRecordID: 2})), expected[1:])
+	deepEq(t, collect(t, snap.Read(journal.Filter{Limit: 2, Latest: true})), expected[1:])
+	deepEq(t, collect(t, snap.ReadReverse(journal.Filter{})), []string{"3:three", "2:two", "1:one"})
+
+	// nor do resealing and deleting the sealed files
+	snap2 := must(j.Snapshot())
+	defer snap2.Close()
+	all := collect(t, snap2.Read(journal.Filter{}))
+	eq(t, len(all), 7)
+	must(j.Reseal(context.Background(), journal.Filter{}))
+	for _, name := range j.FileNames() {
+		if strings.HasPrefix(name, "jS") {
+			ensure(os.Remove(filepath.Join(j.Dir, name)))
+		}
+	}
+	deepEq(t, collect(t, snap2.Read(journal.Filter{})), all)
+	deepEq(t, collect(t, snap2.Read(journal.Filter{Prefetch: 2})), all)
+	deepEq(t, collect(t, snap.Read(journal.Filter{Prefetch: 2})), expected)
+
+	empty := must(setupWritable(t, newClock(), journal.Options{}).Snapshot())
+	deepEq(t, collect(t, empty.Read(journal.Filter{})), []string(nil))
+	ensure(empty.Close())
+}
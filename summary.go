@@ -8,6 +8,10 @@ type Summary struct {
 	SegmentCount         int
 	LastCommitted        Meta
 	LastUncommitted      Meta
+
+	// Gaps lists the records missing between segments. Only filled in by
+	// Journal.Summary.
+	Gaps []Gap
 }
 
 func (s *Summary) FirstRecord() Meta {
@@ -46,3 +46,30 @@ func closeIfNonNil(pf **os.File) {
 		f.Close()
 	}
 }
+
+// writeFileAtomically replaces the file at path with data by writing and
+// syncing a temporary file and renaming it over the original.
+func writeFileAtomically(temp, path string, data []byte) error {
+	f, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return err
+	}
+	var ok bool
+	defer closeAndDeleteUnlessOK(f, &ok)
+
+	_, err = f.Write(data)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return &fsyncFailedError{Cause: err}
+	}
+	ok = true
+	err = f.Close()
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, path)
+}